@@ -0,0 +1,98 @@
+package main
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+var aliasPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// splitAlias splits an input given as file:alias, such as emp.csv:mgr, into
+// the file's path and the alias. Inputs without an alias, including files
+// whose names really do end in :name, return an empty alias.
+func splitAlias(name string) (string, string) {
+
+	for i := len(name) - 1; i > 0; i-- {
+		if name[i] != ':' {
+			continue
+		}
+		if !aliasPattern.MatchString(name[i+1:]) {
+			break
+		}
+		if _, err := os.Stat(name); err == nil {
+			break
+		}
+		return name[:i], name[i+1:]
+	}
+
+	return name, ""
+}
+
+// AliasColumns prefixes the columns of an aliased input with its alias, as
+// mgr.name, so that the same file can be joined to itself without its
+// columns colliding. Columns named by --on, and those --map renamed, are
+// left as they are, as they are how the input is joined.
+func AliasColumns(fileName string, original, mapped []string) []string {
+
+	_, alias := splitAlias(fileName)
+	if alias == "" {
+		return mapped
+	}
+
+	header := make([]string, len(mapped))
+	for i, col := range mapped {
+		if col != original[i] || contains(keyColumns, col) {
+			header[i] = col
+			continue
+		}
+		header[i] = alias + "." + col
+	}
+
+	return header
+}
+
+// DedupeInputs drops inputs naming a file given earlier under the same alias,
+// as happens when a shell glob overlaps another argument, logging each one
+// dropped. Joining a file to itself twice over would only repeat every row.
+// A self-join gives each copy its own alias, as emp.csv:emp emp.csv:mgr.
+func DedupeInputs(fileNames []string) []string {
+
+	type seen struct {
+		path  string
+		info  os.FileInfo
+		alias string
+	}
+
+	kept := []string{}
+	earlier := []seen{}
+
+	for _, name := range fileNames {
+
+		path, alias := splitAlias(name)
+		s := seen{path: filepath.Clean(path), alias: alias}
+		if info, err := os.Stat(path); err == nil {
+			s.info = info
+		}
+
+		duplicate := ""
+		for i, e := range earlier {
+			same := e.path == s.path || (e.info != nil && s.info != nil && os.SameFile(e.info, s.info))
+			if same && e.alias == s.alias {
+				duplicate = kept[i]
+				break
+			}
+		}
+
+		if duplicate != "" {
+			log.Printf("input %s is the same file as %s. ignoring it (to join a file to itself give each copy an alias, as %s:a %s:b)", name, duplicate, path, path)
+			continue
+		}
+
+		kept = append(kept, name)
+		earlier = append(earlier, s)
+	}
+
+	return kept
+}
@@ -0,0 +1,12 @@
+//go:build !windows
+
+package main
+
+// defaultCRLF makes output use Unix line endings by default.
+const defaultCRLF = false
+
+// expandArgs returns the file arguments unchanged: the shell has already
+// expanded any wildcards.
+func expandArgs(args []string) []string {
+	return args
+}
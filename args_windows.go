@@ -0,0 +1,36 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// defaultCRLF makes output use Windows line endings by default.
+const defaultCRLF = true
+
+// expandArgs expands wildcards in file arguments, since cmd.exe and
+// PowerShell pass them through unexpanded. Arguments that match nothing are
+// kept as given, so the error names them. UNC paths (\\server\share\*.csv)
+// are expanded the same way.
+func expandArgs(args []string) []string {
+
+	expanded := []string{}
+
+	for _, arg := range args {
+
+		if !strings.ContainsAny(arg, "*?[") {
+			expanded = append(expanded, arg)
+			continue
+		}
+
+		matches, err := filepath.Glob(arg)
+		if err != nil || len(matches) == 0 {
+			expanded = append(expanded, arg)
+			continue
+		}
+
+		expanded = append(expanded, matches...)
+	}
+
+	return expanded
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+)
+
+var asOfCol = flag.String("as-of", "", "join each row of the first input to the latest row of each other input, with the same key, whose value in this time column is at or before its own (like pandas merge_asof). the column is left out of the key")
+
+// AsOfJoinColumns checks every input has the --as-of column and returns the
+// join columns without it.
+func AsOfJoinColumns(joinColumns []string, allHeaders [][]string, fileNames []string) []string {
+
+	if *asOfCol == "" {
+		return joinColumns
+	}
+
+	for i, header := range allHeaders {
+		if !contains(header, *asOfCol) {
+			fail(&RunError{Class: errSchema, File: fileNames[i], Message: fmt.Sprintf("--as-of column %s not found in %s", *asOfCol, fileNames[i])})
+		}
+	}
+
+	cols := []string{}
+	for _, col := range joinColumns {
+		if col != *asOfCol {
+			cols = append(cols, col)
+		}
+	}
+
+	return cols
+}
+
+// asOfTime reads an --as-of value as a timestamp, or failing that a number,
+// so that either can be ordered.
+func asOfTime(v string) (float64, bool) {
+
+	if t, ok := parseTime(v); ok {
+		return float64(t.UnixNano()), true
+	}
+
+	return parseNumber(v)
+}
+
+// SortAsOf orders each key's records in every input but the first by their
+// --as-of time, for asOfMatch. Records without a readable time are dropped,
+// as they can never be matched.
+func SortAsOf(allData []DataCollection) {
+
+	if *asOfCol == "" {
+		return
+	}
+
+	type timed struct {
+		t   float64
+		rec Record
+	}
+
+	for i := 1; i < len(allData); i++ {
+		unread := 0
+		for key, recs := range allData[i].data {
+			ts := make([]timed, 0, len(recs))
+			for _, rec := range recs {
+				t, ok := asOfTime(rec[*asOfCol])
+				if !ok {
+					unread++
+					continue
+				}
+				ts = append(ts, timed{t, rec})
+			}
+			sort.SliceStable(ts, func(a, b int) bool {
+				return ts[a].t < ts[b].t
+			})
+			recs = recs[:0]
+			for _, t := range ts {
+				recs = append(recs, t.rec)
+			}
+			allData[i].data[key] = recs
+		}
+		CountDrop(inputNames[i], "unreadable "+*asOfCol+" value (--as-of)", unread)
+	}
+}
+
+// asOfMatch returns the last of recs, sorted by SortAsOf, at or before time
+// t, or nil if there is none.
+func asOfMatch(recs []Record, t float64) Record {
+
+	n := sort.Search(len(recs), func(i int) bool {
+		rt, _ := asOfTime(recs[i][*asOfCol])
+		return rt > t
+	})
+
+	if n == 0 {
+		return nil
+	}
+
+	return recs[n-1]
+}
+
+// asOfRows prints one combination for each of the first input's records for
+// key: the record with each other input's as-of match.
+func asOfRows(key string, allData []DataCollection, prt Printer) {
+
+	first := 0
+	for k, orig := range sourceOrder {
+		if orig == 0 {
+			first = k
+		}
+	}
+
+	for _, rec := range allData[first].data[key] {
+		t, ok := asOfTime(rec[*asOfCol])
+		recs := make([]Record, len(allData))
+		recs[first] = rec
+		for k, dc := range allData {
+			if k != first && ok {
+				recs[k] = asOfMatch(dc.data[key], t)
+			}
+		}
+		if !prt(recs) {
+			return
+		}
+	}
+}
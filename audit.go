@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var auditKeysFile = flag.String("audit-keys", "", "write every distinct raw join key to this CSV file, with its normalized form and the files it appeared in")
+
+// auditEntry is what --audit-keys knows about one raw key.
+type auditEntry struct {
+	canonical string
+	files     []bool
+	rows      int
+}
+
+// keyAudit holds the raw keys seen this run, when --audit-keys is set.
+var keyAudit map[string]*auditEntry
+
+// AuditKey records the raw join column values of a record from input src, and
+// the key they were normalized to.
+func AuditKey(rec Record, joinColumns []string, key string, src int) {
+
+	if *auditKeysFile == "" {
+		return
+	}
+
+	if keyAudit == nil {
+		keyAudit = map[string]*auditEntry{}
+	}
+
+	values := make([]string, len(joinColumns))
+	for i, c := range joinColumns {
+		values[i] = rec[c]
+	}
+	raw := JoinKey(values)
+
+	e := keyAudit[raw]
+	if e == nil {
+		e = &auditEntry{canonical: key, files: make([]bool, len(inputNames))}
+		keyAudit[raw] = e
+	}
+	e.files[src] = true
+	e.rows++
+}
+
+// WriteKeyAudit writes the --audit-keys file, ordered so that raw keys
+// sharing a normalized form sit together.
+func WriteKeyAudit() {
+
+	if *auditKeysFile == "" {
+		return
+	}
+
+	raws := make([]string, 0, len(keyAudit))
+	for raw := range keyAudit {
+		raws = append(raws, raw)
+	}
+	sort.Slice(raws, func(i, j int) bool {
+		a, b := keyAudit[raws[i]], keyAudit[raws[j]]
+		if a.canonical != b.canonical {
+			return a.canonical < b.canonical
+		}
+		return raws[i] < raws[j]
+	})
+
+	out := CreateOutput(*auditKeysFile)
+	w := csv.NewWriter(out)
+	w.Write([]string{"raw_key", "normalized_key", "changed", "rows", "files"})
+
+	for _, raw := range raws {
+		e := keyAudit[raw]
+		files := []string{}
+		for i, seen := range e.files {
+			if seen {
+				files = append(files, inputNames[i])
+			}
+		}
+		w.Write([]string{raw, e.canonical, boolString(raw != e.canonical), fmt.Sprint(e.rows), strings.Join(files, ";")})
+		out.Rows++
+	}
+
+	w.Flush()
+	err := w.Error()
+	if err == nil {
+		err = out.Close()
+	}
+	if err != nil {
+		fail(&RunError{Class: errIO, File: *auditKeysFile, Message: fmt.Sprintf("failed writing key audit file: %v", err)})
+	}
+
+	keyAudit = nil
+}
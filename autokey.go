@@ -0,0 +1,349 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	autoKey       = flag.Bool("auto-key", false, "when the inputs share several columns, join on the best scoring subset of them instead of all of them")
+	autoKeySample = flag.Int("auto-key-sample", 1000, "number of rows per input sampled to score candidate join keys")
+	pickKey       = flag.Bool("pick-key", false, "when the inputs share several columns, list them with their scores and ask on the terminal which to join on")
+)
+
+// peekReader replays rows read ahead from a RowReader before reading on.
+// starts holds the byte offset at which each buffered row began, and errAt
+// that of the row that failed, if reading ahead met an error. pos holds the
+// line and column of each field of the buffered rows, and last those of the
+// buffered row most recently replayed.
+type peekReader struct {
+	r      RowReader
+	buf    [][]string
+	starts []int64
+	pos    [][][2]int
+	last   [][2]int
+	err    error
+	errAt  int64
+}
+
+// peek reads a row ahead, keeping it to be replayed. It returns false at the
+// end of the input or on an error, which is kept to be replayed too.
+func (p *peekReader) peek() ([]string, bool) {
+
+	start := inputOffset(p.r)
+	row, err := p.r.Read()
+	if err != nil {
+		if err != io.EOF {
+			p.err, p.errAt = err, start
+		}
+		return nil, false
+	}
+
+	pos := make([][2]int, len(row))
+	for i := range row {
+		pos[i][0], pos[i][1] = fieldPos(p.r, i)
+	}
+
+	p.buf, p.starts, p.pos = append(p.buf, row), append(p.starts, start), append(p.pos, pos)
+
+	return row, true
+}
+
+// Read returns the buffered rows, then any error met while reading ahead,
+// then the rest of the input.
+func (p *peekReader) Read() ([]string, error) {
+
+	if len(p.buf) > 0 {
+		row := p.buf[0]
+		p.last = p.pos[0]
+		p.buf, p.starts, p.pos = p.buf[1:], p.starts[1:], p.pos[1:]
+		return row, nil
+	}
+	p.last = nil
+
+	if p.err != nil {
+		// Reported once, so that a caller skipping bad rows reads on.
+		err := p.err
+		p.err = nil
+		return nil, err
+	}
+
+	return p.r.Read()
+}
+
+// InputOffset returns the offset at which the next row starts.
+func (p *peekReader) InputOffset() int64 {
+
+	switch {
+	case len(p.starts) > 0:
+		return p.starts[0]
+	case p.err != nil:
+		return p.errAt
+	}
+
+	return inputOffset(p.r)
+}
+
+// FieldPos returns the line and column of a field of the last row read.
+func (p *peekReader) FieldPos(field int) (int, int) {
+
+	if p.last != nil {
+		if field < len(p.last) {
+			return p.last[field][0], p.last[field][1]
+		}
+		return 0, 0
+	}
+
+	return fieldPos(p.r, field)
+}
+
+// Unwrap returns the reader the rows are read from.
+func (p *peekReader) Unwrap() RowReader {
+	return p.r
+}
+
+// Close closes the underlying reader, if it can be closed.
+func (p *peekReader) Close() error {
+
+	if c, ok := p.r.(io.Closer); ok {
+		return c.Close()
+	}
+
+	return nil
+}
+
+// keyCandidate is a set of shared columns scored as a join key.
+type keyCandidate struct {
+	columns    []string
+	uniqueness float64
+	overlap    float64
+}
+
+func (c keyCandidate) score() float64 {
+	return c.uniqueness * c.overlap
+}
+
+// SuggestKey scores subsets of the shared columns as join keys, using the
+// first rows of each input, when auto-detection found more than one column
+// and --auto-key or --pick-key asks for it. With --auto-key it logs the best
+// and returns it in place of the full set; with --pick-key it asks which to
+// use. Shared columns left out of the key are reported. The sampled rows are
+// put back so that nothing is lost from the join.
+func SuggestKey(readers []RowReader, allHeaders [][]string, joinColumns []string) []string {
+
+	if len(keyColumns) > 0 || len(joinColumns) < 2 || (!*autoKey && !*pickKey) {
+		return joinColumns
+	}
+
+	shared := append([]string{}, joinColumns...)
+	sort.Strings(shared)
+
+	samples := make([][]Record, len(readers))
+	for i, r := range readers {
+		p := &peekReader{r: r}
+		for len(p.buf) < *autoKeySample {
+			row, ok := p.peek()
+			if !ok {
+				break
+			}
+			rec := Record{}
+			for j, v := range row {
+				if j < len(allHeaders[i]) {
+					rec[allHeaders[i][j]] = v
+				}
+			}
+			samples[i] = append(samples[i], rec)
+		}
+		readers[i] = p
+	}
+
+	normalize := KeyNormalizer()
+
+	best := keyCandidate{}
+	for _, cols := range keySubsets(shared) {
+		c := scoreKey(cols, samples, normalize)
+		if best.columns == nil || c.score() > best.score() || (c.score() == best.score() && len(cols) < len(best.columns)) {
+			best = c
+		}
+	}
+
+	if *pickKey {
+		chosen := promptKey(shared, samples, normalize, best)
+		reportUnusedColumns(shared, chosen)
+		return chosen
+	}
+
+	if best.score() == 0 {
+		log.Printf("shared columns %s: no sampled rows to suggest a join key from", strings.Join(shared, ","))
+		return joinColumns
+	}
+
+	msg := fmt.Sprintf("shared columns %s: best join key from sampled rows is %s (%.0f%% unique, %.0f%% overlap)",
+		strings.Join(shared, ","), strings.Join(best.columns, ","), best.uniqueness*100, best.overlap*100)
+
+	if len(best.columns) == len(shared) {
+		log.Print(msg)
+		return joinColumns
+	}
+
+	log.Print(msg + ". joining on it")
+	reportUnusedColumns(shared, best.columns)
+
+	return best.columns
+}
+
+// promptKey lists the shared columns with the scores of each alone and asks
+// which to join on, by number or name. An empty answer takes the suggested
+// key.
+func promptKey(shared []string, samples [][]Record, normalize func(string) string, best keyCandidate) []string {
+
+	if info, err := os.Stdin.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		usagef("--pick-key asks on the terminal, but stdin is not one. name the join columns with --on")
+	}
+
+	fmt.Fprintln(os.Stderr, "the inputs share these columns:")
+	for i, col := range shared {
+		c := scoreKey([]string{col}, samples, normalize)
+		fmt.Fprintf(os.Stderr, "  %d. %s (%.0f%% unique, %.0f%% overlap)\n", i+1, col, c.uniqueness*100, c.overlap*100)
+	}
+
+	suggested := shared
+	if best.score() > 0 {
+		suggested = best.columns
+	}
+
+	in := bufio.NewScanner(os.Stdin)
+
+	for {
+		fmt.Fprintf(os.Stderr, "join on which columns? (numbers or names, comma separated; enter for %s) ", strings.Join(suggested, ","))
+		if !in.Scan() {
+			usagef("--pick-key: no columns chosen")
+		}
+
+		answer := strings.TrimSpace(in.Text())
+		if answer == "" {
+			return suggested
+		}
+
+		chosen, bad := []string{}, ""
+		for _, item := range parseColumnList(answer) {
+			col := item
+			if n, err := strconv.Atoi(item); err == nil && n >= 1 && n <= len(shared) {
+				col = shared[n-1]
+			}
+			if !contains(shared, col) {
+				bad = item
+				break
+			}
+			if !contains(chosen, col) {
+				chosen = append(chosen, col)
+			}
+		}
+		if bad == "" && len(chosen) > 0 {
+			return chosen
+		}
+		if bad == "" {
+			fmt.Fprintln(os.Stderr, "choose at least one column")
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "%s is not one of the shared columns\n", bad)
+	}
+}
+
+// reportUnusedColumns logs the shared columns that are not part of the join
+// key. They are still output, but their values are not compared.
+func reportUnusedColumns(shared, key []string) {
+
+	unused := []string{}
+	for _, col := range shared {
+		if !contains(key, col) {
+			unused = append(unused, col)
+		}
+	}
+
+	if len(unused) > 0 {
+		log.Printf("joining on %s. shared columns left out of the key, whose values are not compared: %s", strings.Join(key, ","), strings.Join(unused, ","))
+	}
+}
+
+// keySubsets lists the candidate column sets: every subset when there are
+// few columns, otherwise single columns, pairs and the full set.
+func keySubsets(cols []string) [][]string {
+
+	subsets := [][]string{}
+
+	if len(cols) <= 6 {
+		for mask := 1; mask < 1<<len(cols); mask++ {
+			subset := []string{}
+			for i, col := range cols {
+				if mask&(1<<i) != 0 {
+					subset = append(subset, col)
+				}
+			}
+			subsets = append(subsets, subset)
+		}
+		return subsets
+	}
+
+	for i := range cols {
+		subsets = append(subsets, []string{cols[i]})
+		for j := i + 1; j < len(cols); j++ {
+			subsets = append(subsets, []string{cols[i], cols[j]})
+		}
+	}
+
+	return append(subsets, cols)
+}
+
+// scoreKey measures a candidate key over the samples. Uniqueness is the
+// lowest, over the inputs, of distinct keys per row; a good key identifies
+// rows. Overlap is the average, over the inputs after the first, of the
+// fraction of their keys also found in the first; a good key matches across
+// files. Blank keys count against both.
+func scoreKey(cols []string, samples [][]Record, normalize func(string) string) keyCandidate {
+
+	c := keyCandidate{columns: cols, uniqueness: 1}
+
+	sets := make([]map[string]bool, len(samples))
+
+	for i, sample := range samples {
+		sets[i] = map[string]bool{}
+		for _, rec := range sample {
+			values := make([]string, len(cols))
+			blank := true
+			for j, col := range cols {
+				values[j] = normalize(rec[col])
+				blank = blank && strings.TrimSpace(values[j]) == ""
+			}
+			if !blank {
+				sets[i][JoinKey(values)] = true
+			}
+		}
+		if len(sample) == 0 {
+			return keyCandidate{columns: cols}
+		}
+		c.uniqueness = min(c.uniqueness, float64(len(sets[i]))/float64(len(sample)))
+	}
+
+	for _, set := range sets[1:] {
+		found := 0
+		for k := range set {
+			if sets[0][k] {
+				found++
+			}
+		}
+		if len(set) > 0 {
+			c.overlap += float64(found) / float64(len(set))
+		}
+	}
+	c.overlap /= float64(len(sets) - 1)
+
+	return c
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"encoding/csv"
+	"strings"
+	"testing"
+)
+
+func TestPeekReaderReplaysFieldPos(t *testing.T) {
+
+	r := csv.NewReader(strings.NewReader("a,b\n1,22\n333,4\n"))
+	p := &peekReader{r: r}
+	p.peek()
+	p.peek()
+
+	for _, want := range [][2]int{{1, 3}, {2, 3}, {3, 5}} {
+		if _, err := p.Read(); err != nil {
+			t.Fatal(err)
+		}
+		line, col := p.FieldPos(1)
+		if line != want[0] || col != want[1] {
+			t.Errorf("FieldPos(1) = %d,%d, want %d,%d", line, col, want[0], want[1])
+		}
+	}
+}
+
+func TestReuseReachesSourceReader(t *testing.T) {
+
+	defer func(old bool) { *reuseBuffers = old }(*reuseBuffers)
+	*reuseBuffers = true
+
+	sr := sourceReader{Reader: csv.NewReader(strings.NewReader("a\n"))}
+	reuseInputRows(&peekReader{r: sr})
+
+	if !sr.ReuseRecord {
+		t.Error("ReuseRecord not set through a peekReader")
+	}
+}
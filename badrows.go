@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+var maxBadRows = flag.String("max-bad-rows", "", "skip malformed input rows instead of failing, up to this many per input (e.g. 10) or this share of an input's rows (e.g. 0.1%)")
+
+// BadRows counts the malformed rows skipped from one input under
+// --max-bad-rows.
+type BadRows struct {
+	file    string
+	limit   float64
+	percent bool
+	bad     int
+	first   error
+}
+
+// NewBadRows returns the BadRows for an input, or nil if malformed rows are
+// not tolerated.
+func NewBadRows(file string) *BadRows {
+
+	if *maxBadRows == "" {
+		return nil
+	}
+
+	s := strings.TrimSuffix(*maxBadRows, "%")
+	limit, err := strconv.ParseFloat(s, 64)
+	if err != nil || limit < 0 {
+		usagef("--max-bad-rows %s must be a number of rows, such as 10, or a percentage, such as 0.1%%", *maxBadRows)
+	}
+
+	return &BadRows{file: file, limit: limit, percent: s != *maxBadRows}
+}
+
+// Skip reports whether the row that failed to read with err should be
+// skipped. Only malformed CSV is skipped; other errors still fail the run, as
+// does going over a row count limit.
+func (b *BadRows) Skip(err error, rows int) bool {
+
+	var pe *csv.ParseError
+	if !errors.As(err, &pe) {
+		return false
+	}
+
+	b.bad++
+	if b.first == nil {
+		b.first = err
+	}
+	CountDrop(b.file, "malformed row (--max-bad-rows)", 1)
+
+	if !b.percent && float64(b.bad) > b.limit {
+		b.fail(rows)
+	}
+
+	return true
+}
+
+// Finish checks a percentage limit once the input's rows have all been read,
+// and logs how many were skipped.
+func (b *BadRows) Finish(rows int) {
+
+	if b.bad == 0 {
+		return
+	}
+
+	if b.percent && float64(b.bad)*100 > b.limit*float64(rows) {
+		b.fail(rows)
+	}
+
+	log.Printf("%s: skipped %d malformed rows of %d. the first: %v", b.file, b.bad, rows, b.first)
+}
+
+// fail ends the run with the input's bad row statistics.
+func (b *BadRows) fail(rows int) {
+
+	fail(&RunError{
+		Class:   errParse,
+		File:    b.file,
+		Message: fmt.Sprintf("%s has %d malformed rows out of %d read (%.2f%%), more than --max-bad-rows %s allows. the first: %v", b.file, b.bad, rows, 100*float64(b.bad)/float64(max(rows, 1)), *maxBadRows, b.first),
+		Hint:    "check the input for a delimiter or quoting problem",
+	})
+}
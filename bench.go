@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+// benchPreset is a standard dataset for the bench subcommand.
+type benchPreset struct {
+	files   int
+	rows    int
+	columns int
+}
+
+// benchPresets are the datasets bench can generate: wide has many columns,
+// tall many rows and many-files many inputs.
+var benchPresets = map[string]benchPreset{
+	"wide":       {files: 2, rows: 5000, columns: 40},
+	"tall":       {files: 2, rows: 200000, columns: 3},
+	"many-files": {files: 10, rows: 10000, columns: 3},
+}
+
+// benchStrategy is one way of running the join that bench measures.
+type benchStrategy struct {
+	name     string
+	args     []string
+	minFiles int
+}
+
+// benchStrategies are the options that change how a join is carried out
+// without changing its output.
+var benchStrategies = []benchStrategy{
+	{name: "default"},
+	{name: "reuse-buffers", args: []string{"--reuse-buffers"}},
+	{name: "parallel", args: []string{"--parallel=" + strconv.Itoa(runtime.NumCPU())}},
+	{name: "no-reorder", args: []string{"--no-reorder"}, minFiles: 3},
+}
+
+// benchResult is what a measured run reports back to bench.
+type benchResult struct {
+	Nanos      int64  `json:"nanos"`
+	Mallocs    uint64 `json:"mallocs"`
+	AllocBytes uint64 `json:"alloc_bytes"`
+	Rows       int    `json:"rows"`
+}
+
+// Bench implements the bench subcommand: it generates a preset dataset, then
+// joins it with each strategy in a process of its own, so that peak memory
+// is measured separately, and reports the time, allocations and peak RSS of
+// each.
+func Bench(args []string) {
+
+	if len(args) > 0 && args[0] == "--measure" {
+		benchMeasure(args[1:])
+		return
+	}
+
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	presetName := fs.String("preset", "tall", "dataset to generate: wide, tall or many-files")
+	rows := fs.Float64("rows", 0, "rows per file instead of the preset's (1e6 style values are accepted)")
+	benchSeed := fs.Int64("seed", 1, "seed for the generated dataset. 0 picks a seed from the clock and logs it")
+	dir := fs.String("dir", "", "directory to generate the dataset in and keep it. by default a temporary directory is used and removed")
+	fs.Parse(args)
+
+	preset, ok := benchPresets[*presetName]
+	if !ok {
+		usagef("bench --preset %s must be wide, tall or many-files", *presetName)
+	}
+	if *rows < 0 {
+		usagef("bench --rows must not be negative")
+	}
+	if *rows > 0 {
+		preset.rows = int(*rows)
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		fatalf("bench cannot find its own executable: %v", err)
+	}
+
+	data := *dir
+	if data == "" {
+		data, err = os.MkdirTemp("", "csvjoin-bench-")
+		if err != nil {
+			fatalf("bench cannot create a temporary directory: %v", err)
+		}
+		defer os.RemoveAll(data)
+	} else if err := os.MkdirAll(data, 0755); err != nil {
+		fatalf("bench cannot create %s: %v", data, err)
+	}
+
+	fileNames := benchDataset(preset, filepath.Join(data, *presetName), seededRand("bench --seed", *benchSeed))
+
+	fmt.Printf("preset %s: %d files of %d rows, %d columns each besides the key\n", *presetName, preset.files, preset.rows, preset.columns)
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "strategy\ttime\tallocs\tallocated\tpeak rss\trows\n")
+
+	for _, s := range benchStrategies {
+
+		if preset.files < s.minFiles {
+			continue
+		}
+
+		cmd := exec.Command(exe, append(append([]string{"bench", "--measure"}, s.args...), fileNames...)...)
+		stderr := &bytes.Buffer{}
+		cmd.Stderr = stderr
+
+		out, err := cmd.Output()
+		if err != nil {
+			fatalf("bench strategy %s failed: %v\n%s", s.name, err, stderr.String())
+		}
+
+		r := benchResult{}
+		if err := json.Unmarshal(out, &r); err != nil {
+			fatalf("bench strategy %s reported %q: %v", s.name, out, err)
+		}
+
+		rss := "n/a"
+		if peak, ok := peakRSS(cmd.ProcessState); ok {
+			rss = fmt.Sprintf("%.1f MB", float64(peak)/(1<<20))
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%.1f MB\t%s\t%d\n", s.name, time.Duration(r.Nanos).Round(time.Millisecond), r.Mallocs, float64(r.AllocBytes)/(1<<20), rss, r.Rows)
+	}
+
+	tw.Flush()
+}
+
+// benchDataset writes the preset's files, named with the given prefix, and
+// returns their names.
+func benchDataset(preset benchPreset, prefix string, rnd *rand.Rand) []string {
+
+	schema := GenSchema{Key: "id"}
+	types := []string{"string", "float", "date", "int", "bool"}
+	for i := 0; i < preset.columns; i++ {
+		schema.Columns = append(schema.Columns, GenColumn{Name: fmt.Sprintf("col%d", i+1), Type: types[i%len(types)]})
+	}
+
+	g := &generator{
+		rnd:    rnd,
+		schema: schema,
+		rows:   preset.rows,
+		dups:   0.05,
+	}
+
+	fileNames := []string{}
+	for i := 1; i <= preset.files; i++ {
+		firstKey := 0
+		if i > 1 {
+			firstKey = int(float64(g.rows) * 0.3)
+		}
+		fName := fmt.Sprintf("%s%d.csv", prefix, i)
+		g.writeFile(fName, i, firstKey)
+		fileNames = append(fileNames, fName)
+	}
+
+	return fileNames
+}
+
+// benchMeasure runs one join for bench, discarding the output, and writes
+// its time and allocations to stdout as JSON.
+func benchMeasure(args []string) {
+
+	flag.CommandLine.Parse(args)
+	ApplyOn()
+
+	fileNames := flag.Args()
+	readers := OpenReaders(fileNames)
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+
+	out := csv.NewWriter(io.Discard)
+	counter := &benchWriter{out: out}
+	ow := NewOutputWriter(counter)
+	Run(readers, fileNames, ow)
+	ow.Flush()
+	out.Flush()
+
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+
+	json.NewEncoder(os.Stdout).Encode(benchResult{
+		Nanos:      elapsed.Nanoseconds(),
+		Mallocs:    after.Mallocs - before.Mallocs,
+		AllocBytes: after.TotalAlloc - before.TotalAlloc,
+		Rows:       max(counter.rows-1, 0),
+	})
+}
+
+// benchWriter encodes rows as CSV, as a real run would, and counts them.
+type benchWriter struct {
+	out  *csv.Writer
+	rows int
+}
+
+// Write encodes and counts the row.
+func (b *benchWriter) Write(row []string) error {
+
+	b.rows++
+
+	return b.out.Write(row)
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"flag"
+	"strings"
+)
+
+var boolCols = flag.String("bool-cols", "", "comma separated boolean columns whose values (Y/N, yes/no, true/false, 1/0) are rewritten as true or false as they are read, so they match as keys and print alike")
+
+// boolValues maps the spellings accepted in --bool-cols columns, lower cased,
+// to their canonical form.
+var boolValues = map[string]string{
+	"y": "true", "yes": "true", "t": "true", "true": "true", "1": "true",
+	"n": "false", "no": "false", "f": "false", "false": "false", "0": "false",
+}
+
+// boolColumns returns the --bool-cols columns found in headers.
+func boolColumns(headers []string) []string {
+
+	cols := []string{}
+	for _, col := range strings.Split(*boolCols, ",") {
+		if col != "" && contains(headers, col) {
+			cols = append(cols, col)
+		}
+	}
+
+	return cols
+}
+
+// canonicalBool returns true or false for a boolean spelling, and other
+// values (such as empty ones) unchanged.
+func canonicalBool(v string) string {
+
+	if b, ok := boolValues[strings.ToLower(strings.TrimSpace(v))]; ok {
+		return b
+	}
+
+	return v
+}
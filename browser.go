@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// BrowserOption is one option passed to JoinText: a command line option
+// name, without dashes, and its value. Repeatable options may appear more
+// than once. The name "keys" sets the join columns.
+type BrowserOption struct {
+	Name  string
+	Value string
+}
+
+// JoinText joins CSV inputs held as text, returning the output as text. It is
+// the entry point for the browser build, which has no files: every option is
+// reset to its default first, so one call doesn't leak into the next, and
+// fatal errors are returned rather than ending the program.
+func JoinText(names []string, texts []string, options []BrowserOption) (output string, err error) {
+
+	defer func(saved func(*RunError)) {
+		fail = saved
+		if r := recover(); r != nil {
+			msg, ok := r.(replAbort)
+			if !ok {
+				panic(r)
+			}
+			err = errors.New(string(msg))
+		}
+	}(fail)
+
+	fail = func(e *RunError) {
+		panic(replAbort(e.Message))
+	}
+
+	flag.VisitAll(func(f *flag.Flag) {
+		if r, ok := f.Value.(interface{ Reset() }); ok {
+			r.Reset()
+		} else {
+			f.Value.Set(f.DefValue)
+		}
+	})
+	ApplyOn()
+
+	for _, o := range options {
+		if o.Name == "keys" {
+			keyColumns = strings.Split(o.Value, ",")
+			continue
+		}
+		if flag.Lookup(o.Name) == nil {
+			return "", fmt.Errorf("no such option: %s", o.Name)
+		}
+		if err := flag.Set(o.Name, o.Value); err != nil {
+			return "", fmt.Errorf("option %s: %v", o.Name, err)
+		}
+		if o.Name == "on" {
+			ApplyOn()
+		}
+	}
+
+	if len(names) < 2 {
+		return "", errors.New("at least two inputs are needed")
+	}
+
+	term, terminated := InputTerminator()
+	commas := InputDelimiters(names)
+	readers := []RowReader{}
+	for i, text := range texts {
+		r := csv.NewReader(TerminatedReader(strings.NewReader(text)))
+		r.Comma = commas[i]
+		readers = append(readers, sourceReader{Reader: r, Closer: io.NopCloser(nil), term: term, terminated: terminated})
+	}
+
+	buf := &bytes.Buffer{}
+	out := NewOutputEncoder(buf)
+	ow := NewOutputWriter(out)
+
+	Run(readers, names, ow)
+
+	ow.Flush()
+	out.Flush()
+	if err := out.Error(); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
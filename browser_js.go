@@ -0,0 +1,78 @@
+//go:build js && wasm
+
+package main
+
+import (
+	"fmt"
+	"syscall/js"
+)
+
+// startBrowser publishes the join function to JavaScript as csvjoinText and
+// keeps the program alive to serve calls. See wasm/csvjoin.js.
+func startBrowser() bool {
+
+	js.Global().Set("csvjoinText", js.FuncOf(func(this js.Value, args []js.Value) any {
+
+		result := map[string]any{"output": "", "error": ""}
+
+		if len(args) < 2 {
+			result["error"] = "csvjoinText needs names and texts"
+			return result
+		}
+
+		names, texts := jsStrings(args[0]), jsStrings(args[1])
+		options := []BrowserOption{}
+		if len(args) > 2 && args[2].Truthy() {
+			options = jsOptions(args[2])
+		}
+
+		output, err := JoinText(names, texts, options)
+		result["output"] = output
+		if err != nil {
+			result["error"] = err.Error()
+		}
+
+		return result
+	}))
+
+	select {}
+}
+
+// jsStrings reads a JavaScript array of strings.
+func jsStrings(v js.Value) []string {
+
+	s := make([]string, v.Length())
+	for i := range s {
+		s[i] = v.Index(i).String()
+	}
+
+	return s
+}
+
+// jsOptions reads an array of [name, value] pairs.
+func jsOptions(v js.Value) []BrowserOption {
+
+	options := []BrowserOption{}
+	for i := 0; i < v.Length(); i++ {
+		pair := v.Index(i)
+		options = append(options, BrowserOption{
+			Name:  pair.Index(0).String(),
+			Value: fmt.Sprint(jsValue(pair.Index(1))),
+		})
+	}
+
+	return options
+}
+
+// jsValue converts the JavaScript values an option may hold.
+func jsValue(v js.Value) any {
+
+	switch v.Type() {
+	case js.TypeBoolean:
+		return v.Bool()
+	case js.TypeNumber:
+		return v.Float()
+	}
+
+	return v.String()
+}
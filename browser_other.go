@@ -0,0 +1,8 @@
+//go:build !(js && wasm)
+
+package main
+
+// startBrowser does nothing outside the browser build.
+func startBrowser() bool {
+	return false
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"flag"
+	"strings"
+	"time"
+)
+
+var bucketOpt = flag.String("bucket", "", "join on time buckets, as file:col=unit,... with unit minute, hour, day or week. every input needs an entry")
+
+// bucketSpec is the time bucket applied to one input.
+type bucketSpec struct {
+	column string
+	unit   string
+}
+
+// buckets holds the bucket for each input, indexed like inputNames. It is
+// empty when --bucket is not used.
+var buckets []bucketSpec
+
+// ResolveBuckets parses --bucket against the inputs.
+func ResolveBuckets(fileNames []string, allHeaders [][]string) {
+
+	buckets = nil
+
+	if *bucketOpt == "" {
+		return
+	}
+
+	buckets = make([]bucketSpec, len(fileNames))
+
+	for _, spec := range strings.Split(*bucketOpt, ",") {
+
+		file, rest, ok := splitPair(spec, ":")
+		col, unit, ok2 := splitPair(rest, "=")
+		if !ok || !ok2 {
+			usagef("--bucket entry %s must be file:col=unit", spec)
+		}
+
+		i := FileIndex(fileNames, file)
+		if i < 0 {
+			usagef("--bucket names %s, which is not an input file", file)
+		}
+		if !contains(allHeaders[i], col) {
+			usagef("--bucket column %s not found in %s", col, fileNames[i])
+		}
+		if bucketSize(unit) == 0 {
+			usagef("--bucket unit %s must be minute, hour, day or week", unit)
+		}
+
+		buckets[i] = bucketSpec{column: col, unit: unit}
+	}
+
+	for i, b := range buckets {
+		if b.column == "" {
+			usagef("--bucket has no entry for %s", fileNames[i])
+		}
+	}
+}
+
+// isBucketColumn reports whether col is used as a bucket column by any input.
+func isBucketColumn(col string) bool {
+
+	for _, b := range buckets {
+		if b.column == col {
+			return true
+		}
+	}
+
+	return false
+}
+
+// bucketSize returns the duration of a bucket unit, or 0 if unknown.
+func bucketSize(unit string) time.Duration {
+
+	switch unit {
+	case "minute":
+		return time.Minute
+	case "hour":
+		return time.Hour
+	case "day":
+		return 24 * time.Hour
+	case "week":
+		// Truncation is measured from the zero time, which was a Monday, so
+		// weeks start on Monday.
+		return 7 * 24 * time.Hour
+	}
+
+	return 0
+}
+
+// bucketOf returns the canonical bucket a timestamp falls in.
+func bucketOf(v string, unit string) (string, bool) {
+
+	t, ok := parseTime(v)
+	if !ok {
+		return "", false
+	}
+
+	return t.UTC().Truncate(bucketSize(unit)).Format(time.RFC3339), true
+}
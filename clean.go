@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+	"strings"
+)
+
+var stripQuotes = flag.String("strip-embedded-quotes", "", "comma separated columns whose values have literal surrounding quotes removed as they are read")
+
+// RecordCleaner builds the function applying the read-time cleanups to the
+// records of an input with the given headers, or returns nil if there are
+// none. Cleanups run before keys are computed, so they affect both matching
+// and output.
+func RecordCleaner(headers []string) func(Record) {
+
+	cols := []string{}
+	for _, col := range strings.Split(*stripQuotes, ",") {
+		if col != "" && contains(headers, col) {
+			cols = append(cols, col)
+		}
+	}
+
+	bools := boolColumns(headers)
+
+	subs := map[string][]*Substitution{}
+	if *replaceAt == "read" {
+		for col, s := range columnSubstitutions() {
+			if contains(headers, col) {
+				subs[col] = s
+			}
+		}
+	}
+
+	recode := recodeTables(headers)
+
+	if len(cols) == 0 && len(bools) == 0 && len(subs) == 0 && len(recode) == 0 {
+		return nil
+	}
+
+	return func(rec Record) {
+		for _, col := range cols {
+			rec[col] = stripEmbeddedQuotes(rec[col])
+		}
+		for _, col := range bools {
+			rec[col] = canonicalBool(rec[col])
+		}
+		for col, subs := range subs {
+			for _, s := range subs {
+				rec[col] = s.Apply(rec[col])
+			}
+		}
+		for col, table := range recode {
+			if v, ok := table[rec[col]]; ok {
+				rec[col] = v
+			}
+		}
+	}
+}
+
+// stripEmbeddedQuotes removes any number of layers of literal double quotes
+// wrapped around a value, as left by exports that quote values twice.
+func stripEmbeddedQuotes(v string) string {
+
+	for len(v) >= 2 && v[0] == '"' && v[len(v)-1] == '"' {
+		v = v[1 : len(v)-1]
+	}
+
+	return v
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"regexp"
+	"strings"
+)
+
+var collapseOpts listFlag
+
+func init() {
+	flag.Var(&collapseOpts, "collapse", "combine an input's rows for a key into one row instead of one output row each, as 'file:col=concat(|)': col's values are joined with | and other columns take the first row's value. may be repeated")
+}
+
+var concatPattern = regexp.MustCompile(`^concat\((.*)\)$`)
+
+// collapsing is the --collapse options for one input: the separator each
+// concatenated column is joined with.
+type collapsing map[string]string
+
+// CollapseInputs applies --collapse, merging each collapsed input's records
+// for a key into one.
+func CollapseInputs(allData []DataCollection, allHeaders [][]string, fileNames []string) {
+
+	byInput := map[int]collapsing{}
+
+	for _, spec := range collapseOpts {
+
+		file, rule, ok := splitPair(spec, ":")
+		col, fn, ok2 := splitPair(rule, "=")
+		m := concatPattern.FindStringSubmatch(fn)
+		if !ok || !ok2 || m == nil {
+			usagef("--collapse %s must be file:col=concat(sep)", spec)
+		}
+
+		i := FileIndex(fileNames, file)
+		if i < 0 {
+			usagef("--collapse names %s, which is not an input file", file)
+		}
+		if !contains(allHeaders[i], col) {
+			usagef("--collapse: %s has no column %s", fileNames[i], col)
+		}
+
+		if byInput[i] == nil {
+			byInput[i] = collapsing{}
+		}
+		byInput[i][col] = m[1]
+	}
+
+	for i := range allData {
+
+		c := byInput[i]
+		if c == nil {
+			continue
+		}
+
+		rows, keys := 0, 0
+		for key, recs := range allData[i].data {
+			rows += len(recs)
+			keys++
+			if len(recs) > 1 {
+				allData[i].data[key] = []Record{c.merge(recs)}
+				for _, rec := range recs {
+					putRecord(rec)
+				}
+			}
+		}
+
+		if rows > keys {
+			log.Printf("collapsed %d rows of %s into %d", rows, fileNames[i], keys)
+		}
+	}
+}
+
+// merge combines records into one: the first record, with each collapsed
+// column holding all the records' values joined by its separator.
+func (c collapsing) merge(recs []Record) Record {
+
+	out := make(Record, len(recs[0]))
+	for col, v := range recs[0] {
+		out[col] = v
+	}
+
+	for col, sep := range c {
+		values := make([]string, len(recs))
+		for j, rec := range recs {
+			values[j] = rec[col]
+		}
+		out[col] = strings.Join(values, sep)
+	}
+
+	return out
+}
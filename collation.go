@@ -0,0 +1,169 @@
+package main
+
+import (
+	"flag"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+var (
+	collationOpt = flag.String("collation", "", "order output keys by language rules: tr (Turkish), de (German) or unicode-ci (ignoring case and accents first)")
+	collateKeys  = flag.Bool("collate-keys", false, "with --collation, also match join keys ignoring case by the collation's rules, so that İSTANBUL matches istanbul under tr")
+)
+
+// Collator folds case and orders strings by the rules of a --collation.
+type Collator struct {
+	name string
+
+	// fold maps a string to its case-insensitive form.
+	fold func(string) string
+
+	// weigh maps a folded string to the primary weights it sorts by, in
+	// which accents are ignored or letters ordered as the language orders
+	// them.
+	weigh func(string) []int
+}
+
+// turkishAlphabet is the Turkish lower case alphabet in order, with q, w and x
+// placed as in the Latin alphabet.
+const turkishAlphabet = "abcçdefgğhıijklmnoöpqrsştuüvwxyz"
+
+// Collation returns the Collator for --collation, or nil if none is set.
+func Collation() *Collator {
+
+	switch *collationOpt {
+
+	case "":
+		return nil
+
+	case "tr":
+		return &Collator{
+			name: "tr",
+			fold: func(s string) string {
+				return strings.ToLowerSpecial(unicode.TurkishCase, s)
+			},
+			weigh: turkishWeights,
+		}
+
+	case "de":
+		return &Collator{
+			name: "de",
+			fold: func(s string) string {
+				s = strings.ReplaceAll(strings.ToLower(s), "ẞ", "ß")
+				return strings.ReplaceAll(s, "ß", "ss")
+			},
+			weigh: stripAccents,
+		}
+
+	case "unicode-ci":
+		return &Collator{
+			name: "unicode-ci",
+			fold: func(s string) string {
+				return strings.ToLower(strings.ToUpper(s))
+			},
+			weigh: stripAccents,
+		}
+	}
+
+	usagef("--collation %s must be tr, de or unicode-ci", *collationOpt)
+
+	return nil
+}
+
+// turkishWeights weighs a folded string by the Turkish alphabet, so that ç
+// sorts between c and d and ı before i. Characters before a, such as digits
+// and punctuation, weigh their code point; other letters come after z.
+func turkishWeights(s string) []int {
+
+	alphabet := []rune(turkishAlphabet)
+	w := []int{}
+
+	for _, r := range s {
+		switch i := slices.Index(alphabet, r); {
+		case i >= 0:
+			w = append(w, 'a'+i)
+		case r < 'a':
+			w = append(w, int(r))
+		default:
+			w = append(w, 'a'+len(alphabet)+int(r))
+		}
+	}
+
+	return w
+}
+
+// stripAccents weighs a folded string by its letters with accents removed, so
+// that ä sorts with a, as German dictionaries do.
+func stripAccents(s string) []int {
+
+	w := []int{}
+
+	for _, r := range s {
+		if base, ok := latinBase[r]; ok {
+			for _, b := range strings.ToLower(base) {
+				w = append(w, int(b))
+			}
+			continue
+		}
+		w = append(w, int(r))
+	}
+
+	return w
+}
+
+// SortKeys sorts the join keys for output, by --collation if one is set:
+// by primary weight, then by folded form, which separates accents, then by
+// the keys themselves, which separates case.
+func SortKeys(keys []string) {
+
+	c := Collation()
+	if c == nil {
+		sort.Strings(keys)
+		return
+	}
+
+	type sortable struct {
+		key    string
+		folded string
+		weight []int
+	}
+
+	items := make([]sortable, len(keys))
+	for i, k := range keys {
+		f := c.fold(k)
+		items[i] = sortable{key: k, folded: f, weight: c.weigh(f)}
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		a, b := items[i], items[j]
+		if n := slices.Compare(a.weight, b.weight); n != 0 {
+			return n < 0
+		}
+		if a.folded != b.folded {
+			return a.folded < b.folded
+		}
+		return a.key < b.key
+	})
+
+	for i := range items {
+		keys[i] = items[i].key
+	}
+}
+
+// keyFolder returns the --collate-keys case folding for join key values, or
+// nil if keys are matched exactly.
+func keyFolder() func(string) string {
+
+	if !*collateKeys {
+		return nil
+	}
+
+	c := Collation()
+	if c == nil {
+		usagef("--collate-keys needs --collation")
+	}
+
+	return c.fold
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestTurkishWeights(t *testing.T) {
+
+	// Each pair is in Turkish alphabetical order.
+	ordered := [][2]string{
+		{"c", "ç"},
+		{"ç", "d"},
+		{"g", "ğ"},
+		{"ğ", "h"},
+		{"ı", "i"},
+		{"h", "ı"},
+		{"o", "ö"},
+		{"s", "ş"},
+		{"ş", "t"},
+		{"u", "ü"},
+		{"1", "a"},
+		{"z", "ä"},
+		{"ab", "abc"},
+	}
+
+	for _, p := range ordered {
+		if slices.Compare(turkishWeights(p[0]), turkishWeights(p[1])) >= 0 {
+			t.Errorf("%q does not weigh before %q", p[0], p[1])
+		}
+	}
+}
+
+func TestSortKeys(t *testing.T) {
+
+	defer func(old string) { *collationOpt = old }(*collationOpt)
+
+	tests := []struct {
+		collation string
+		keys      []string
+		want      []string
+	}{
+		{"", []string{"b", "B", "a", "ä"}, []string{"B", "a", "b", "ä"}},
+		{"tr", []string{"zeytin", "çay", "ılık", "irmik", "cam", "İstanbul", "Izmir"}, []string{"cam", "çay", "ılık", "Izmir", "irmik", "İstanbul", "zeytin"}},
+		{"de", []string{"Zebra", "Äpfel", "Apfel", "Straße", "Strasse", "ab"}, []string{"ab", "Apfel", "Äpfel", "Strasse", "Straße", "Zebra"}},
+		{"unicode-ci", []string{"b", "É", "e", "A", "a"}, []string{"A", "a", "b", "e", "É"}},
+	}
+
+	for _, tt := range tests {
+		*collationOpt = tt.collation
+		keys := append([]string{}, tt.keys...)
+		SortKeys(keys)
+		if !reflect.DeepEqual(keys, tt.want) {
+			t.Errorf("--collation %q sorts %q as %q, want %q", tt.collation, tt.keys, keys, tt.want)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	mapOpts    listFlag
+	byPosition = flag.String("by-position", "", "join on the columns at these positions, counting from 1, as 1,3, whatever each input calls them. the columns take the first input's names")
+)
+
+func init() {
+	flag.Var(&mapOpts, "map", "rename an input's columns before joining, as file:uid=user_id,acct=account_id, so differently named key columns match. may be repeated")
+}
+
+// MapColumns returns the header of input src with its --map renames applied.
+// Later options, such as --on, refer to the new names.
+func MapColumns(fileNames []string, src int, header []string) []string {
+
+	mapped := append([]string{}, header...)
+
+	for _, spec := range mapOpts {
+
+		file, renames, ok := splitPair(spec, ":")
+		if !ok {
+			usagef("--map %s must be file:from=to,...", spec)
+		}
+
+		i := FileIndex(fileNames, file)
+		if i < 0 {
+			usagef("--map names %s, which is not an input file", file)
+		}
+		if i != src {
+			continue
+		}
+
+		for _, rename := range strings.Split(renames, ",") {
+			from, to, ok := splitPair(rename, "=")
+			if !ok {
+				usagef("--map %s must be file:from=to,...", spec)
+			}
+			at := indexOf(mapped, from)
+			if at < 0 {
+				usagef("--map: %s has no column %s", fileNames[i], from)
+			}
+			if contains(mapped, to) {
+				usagef("--map: %s already has a column %s", fileNames[i], to)
+			}
+			mapped[at] = to
+		}
+	}
+
+	return mapped
+}
+
+// PositionColumns applies --by-position to the inputs' headers: the columns
+// at the positions given are renamed to the first input's names for them,
+// and become the join columns.
+func PositionColumns(headers [][]string, fileNames []string) {
+
+	if *byPosition == "" {
+		return
+	}
+	if *on != "" {
+		usagef("use either --on or --by-position, not both")
+	}
+
+	positions := []int{}
+	for _, p := range strings.Split(*byPosition, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(p))
+		if err != nil || n < 1 {
+			usagef("--by-position %s must list column positions, counting from 1", *byPosition)
+		}
+		positions = append(positions, n-1)
+	}
+
+	for i, header := range headers {
+		for _, p := range positions {
+			if p >= len(header) {
+				fail(&RunError{Class: errSchema, File: fileNames[i], Message: fmt.Sprintf("--by-position: %s has only %d columns, so no column %d", fileNames[i], len(header), p+1)})
+			}
+		}
+	}
+
+	keyColumns = nil
+	for _, p := range positions {
+		keyColumns = append(keyColumns, headers[0][p])
+	}
+
+	for i, header := range headers[1:] {
+		for j, p := range positions {
+			name := keyColumns[j]
+			if at := indexOf(header, name); at >= 0 && at != p {
+				fail(&RunError{
+					Class:   errSchema,
+					File:    fileNames[i+1],
+					Message: fmt.Sprintf("--by-position: %s column %d would be renamed %s, but its column %d already has that name", fileNames[i+1], p+1, name, at+1),
+					Hint:    "rename the other column with --map",
+				})
+			}
+			header[p] = name
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+var headerComments = flag.Bool("header-comments", false, "write # comment lines describing the run (time, inputs, join columns, version) before the header row")
+
+// version is the csvjoin version, set at build time with
+// -ldflags "-X main.version=v1.2.3".
+var version = "dev"
+
+// WriteHeaderComments writes the --header-comments preamble to w.
+func WriteHeaderComments(w io.Writer, fileNames []string) error {
+
+	eol := "\n"
+	if *crlf {
+		eol = "\r\n"
+	}
+
+	lines := []string{
+		fmt.Sprintf("csvjoin %s", version),
+		fmt.Sprintf("run at %s", time.Now().UTC().Format(time.RFC3339)),
+		fmt.Sprintf("inputs: %s", strings.Join(fileNames, ", ")),
+	}
+	if len(resolvedJoinColumns) > 0 {
+		lines = append(lines, fmt.Sprintf("join columns: %s", strings.Join(resolvedJoinColumns, ", ")))
+	}
+
+	for _, line := range lines {
+		_, err := io.WriteString(w, "# "+line+eol)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// CheckCompat implements the check-compat subcommand: it reads the headers
+// and a sample of rows from each input and reports how well they would join,
+// failing if the best candidate key overlaps less than --min-overlap.
+func CheckCompat(args []string) {
+
+	fs := flag.NewFlagSet("check-compat", flag.ExitOnError)
+	sample := fs.Int("sample", 1000, "number of rows per input to sample")
+	minOverlap := fs.Float64("min-overlap", 50, "fail unless the best candidate key's overlap is at least this percentage")
+	fs.Parse(args)
+
+	fileNames := expandArgs(fs.Args())
+	if len(fileNames) < 2 {
+		usagef("check-compat needs at least two input files")
+	}
+	inputNames = fileNames
+
+	readers := OpenReaders(fileNames)
+	allHeaders := GatherAllHeaders(readers, fileNames)
+
+	samples := make([][]Record, len(readers))
+	for i, r := range readers {
+		for len(samples[i]) < *sample {
+			offset := inputOffset(r)
+			row, err := r.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				parseFailure(fileNames[i], err, len(samples[i])+1, offset)
+			}
+			rec := Record{}
+			for j, v := range row {
+				rec[allHeaders[i][j]] = v
+			}
+			samples[i] = append(samples[i], rec)
+		}
+		releaseReader(readers, i)
+	}
+
+	shared := []string{}
+	for _, col := range allHeaders[0] {
+		inAll := true
+		for _, header := range allHeaders[1:] {
+			inAll = inAll && contains(header, col)
+		}
+		if inAll {
+			shared = append(shared, col)
+		}
+	}
+
+	if len(shared) == 0 {
+		fail(&RunError{Class: errSchema, Message: "the inputs have no columns in common", Hint: "the inputs must share at least one column name"})
+	}
+
+	fmt.Printf("shared columns: %s\n\n", strings.Join(shared, ", "))
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "column\t%s\tmatch\n", strings.Join(fileNames, "\t"))
+	for _, col := range shared {
+		types := []string{}
+		for _, s := range samples {
+			types = append(types, columnType(s, col))
+		}
+		verdict := "ok"
+		if !typesCompatible(types) {
+			verdict = "MISMATCH"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", col, strings.Join(types, "\t"), verdict)
+	}
+	tw.Flush()
+
+	normalize := KeyNormalizer()
+	candidates := []keyCandidate{}
+	for _, cols := range keySubsets(shared) {
+		candidates = append(candidates, scoreKey(cols, samples, normalize))
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].score() != candidates[j].score() {
+			return candidates[i].score() > candidates[j].score()
+		}
+		return len(candidates[i].columns) < len(candidates[j].columns)
+	})
+
+	fmt.Printf("\ncandidate keys, from up to %d rows per input:\n", *sample)
+	tw = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for i, c := range candidates {
+		if i == 5 {
+			break
+		}
+		fmt.Fprintf(tw, "  %s\t%.0f%% unique\t%.0f%% overlap\n", strings.Join(c.columns, ","), c.uniqueness*100, c.overlap*100)
+	}
+	tw.Flush()
+
+	best := candidates[0]
+	if best.overlap*100 < *minOverlap {
+		fail(&RunError{
+			Class:   errPolicy,
+			Message: fmt.Sprintf("best candidate key %s overlaps %.0f%%, below --min-overlap %.0f%%", strings.Join(best.columns, ","), best.overlap*100, *minOverlap),
+		})
+	}
+}
+
+// columnType names the kind of values a column holds in a sample: int,
+// number, date, bool, text, or empty if every value is blank.
+func columnType(sample []Record, col string) string {
+
+	kinds := map[string]bool{}
+
+	for _, rec := range sample {
+		v := strings.TrimSpace(rec[col])
+		switch {
+		case v == "":
+			continue
+		case isInt(v):
+			kinds["int"] = true
+		case isNumber(v):
+			kinds["number"] = true
+		case v == "true" || v == "false":
+			kinds["bool"] = true
+		case isTime(v):
+			kinds["date"] = true
+		default:
+			kinds["text"] = true
+		}
+	}
+
+	switch {
+	case len(kinds) == 0:
+		return "empty"
+	case len(kinds) == 1:
+		for k := range kinds {
+			return k
+		}
+	case len(kinds) == 2 && kinds["int"] && kinds["number"]:
+		return "number"
+	}
+
+	return "text"
+}
+
+func isInt(v string) bool {
+	_, err := strconv.ParseInt(v, 10, 64)
+	return err == nil
+}
+
+func isNumber(v string) bool {
+	_, ok := parseNumber(v)
+	return ok
+}
+
+func isTime(v string) bool {
+	_, ok := parseTime(v)
+	return ok
+}
+
+// typesCompatible reports whether columns of the given types can be expected
+// to match: the same type, ints against numbers, or a column with no values.
+func typesCompatible(types []string) bool {
+
+	kind := ""
+
+	for _, t := range types {
+		switch t {
+		case "empty":
+			continue
+		case "int":
+			t = "number"
+		}
+		if kind != "" && t != kind {
+			return false
+		}
+		kind = t
+	}
+
+	return true
+}
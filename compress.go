@@ -0,0 +1,108 @@
+package main
+
+import (
+	"compress/gzip"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+)
+
+var (
+	outputCompression = flag.String("output-compression", "none", "compress every output file: gzip, zstd or bzip2 (through the zstd and bzip2 commands), or none. stdout is never compressed")
+	compressionLevel  = flag.Int("compression-level", 0, "level for --output-compression: 1 to 9 for gzip and bzip2, 1 to 19 for zstd. 0 uses the codec's default")
+)
+
+// compressorLevels are the highest levels of each codec.
+var compressorLevels = map[string]int{"gzip": 9, "zstd": 19, "bzip2": 9}
+
+// checkCompression validates --output-compression and --compression-level.
+func checkCompression() {
+
+	if *outputCompression == "none" {
+		return
+	}
+
+	top, ok := compressorLevels[*outputCompression]
+	if !ok {
+		usagef("--output-compression %s must be gzip, zstd, bzip2 or none", *outputCompression)
+	}
+	if *compressionLevel < 0 || *compressionLevel > top {
+		usagef("--compression-level %d must be between 1 and %d for %s", *compressionLevel, top, *outputCompression)
+	}
+}
+
+// Compress returns a writer compressing into w by --output-compression, or
+// nil if output is not compressed. Closing it finishes the compressed
+// stream, but leaves w open.
+func Compress(name string, w io.Writer) io.WriteCloser {
+
+	checkCompression()
+
+	switch *outputCompression {
+	case "none":
+		return nil
+	case "gzip":
+		level := gzip.DefaultCompression
+		if *compressionLevel > 0 {
+			level = *compressionLevel
+		}
+		z, err := gzip.NewWriterLevel(w, level)
+		if err != nil {
+			usagef("--compression-level: %v", err)
+		}
+		return z
+	}
+
+	args := []string{"-c", "-q"}
+	if *outputCompression == "zstd" {
+		args = append(args, "--no-progress")
+	}
+	if *compressionLevel > 0 {
+		args = append(args, "-"+strconv.Itoa(*compressionLevel))
+	}
+
+	cmd := exec.Command(*outputCompression, args...)
+	cmd.Stdout = w
+	cmd.Stderr = os.Stderr
+
+	in, err := cmd.StdinPipe()
+	if err == nil {
+		err = cmd.Start()
+	}
+	if err != nil {
+		fail(&RunError{
+			Class:   errIO,
+			File:    name,
+			Message: fmt.Sprintf("cannot start %s to compress %s: %v", *outputCompression, name, err),
+			Hint:    "install the " + *outputCompression + " command, or use --output-compression gzip",
+		})
+	}
+
+	return &commandWriter{in: in, cmd: cmd}
+}
+
+// commandWriter writes to a command's input.
+type commandWriter struct {
+	in  io.WriteCloser
+	cmd *exec.Cmd
+}
+
+// Write writes to the command.
+func (c *commandWriter) Write(p []byte) (int, error) {
+	return c.in.Write(p)
+}
+
+// Close ends the command's input and waits for it to finish, reporting its
+// failure, if any.
+func (c *commandWriter) Close() error {
+
+	err := c.in.Close()
+	if werr := c.cmd.Wait(); werr != nil {
+		return fmt.Errorf("compress command %s failed: %v", c.cmd.Args[0], werr)
+	}
+
+	return err
+}
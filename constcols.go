@@ -0,0 +1,34 @@
+package main
+
+import (
+	"flag"
+	"strings"
+)
+
+var constOpts listFlag
+
+func init() {
+	flag.Var(&constOpts, "const", "add an output column holding the same value on every row, as name=value (e.g. batch_date=2024-06-01). may be repeated")
+}
+
+// ConstColumns parses --const against the output header, returning the names
+// and values of the columns to add.
+func ConstColumns(header []string) ([]string, []string) {
+
+	names, values := []string{}, []string{}
+
+	for _, spec := range constOpts {
+		// The value may be empty, so splitPair won't do.
+		name, value, ok := strings.Cut(spec, "=")
+		if !ok || name == "" {
+			usagef("--const %s must be name=value", spec)
+		}
+		if contains(header, name) || contains(names, name) {
+			usagef("--const %s: there is already an output column %s", spec, name)
+		}
+		names = append(names, name)
+		values = append(values, value)
+	}
+
+	return names, values
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+var crashDir = flag.String("crash-dir", os.TempDir(), "directory to write a crash report to if csvjoin panics")
+
+// current is what the run is working on, for crash reports. It is only
+// written by the main goroutine.
+var current struct {
+	file   string
+	record int
+	key    string
+}
+
+// secretFlags are options whose values are left out of crash reports.
+var secretFlags = map[string]bool{"notify-webhook": true}
+
+// RecoverCrash is deferred by main. On a panic it writes a crash report,
+// removes any half written outputs and exits.
+func RecoverCrash() {
+
+	r := recover()
+	if r == nil {
+		return
+	}
+
+	crashed(r, current.key)
+}
+
+// crashed writes the crash report for a panic and exits. key is the key being
+// worked on, which in a --parallel worker is not current.key.
+func crashed(r any, key string) {
+
+	stack := debug.Stack()
+
+	sb := strings.Builder{}
+	fmt.Fprintf(&sb, "csvjoin %s crashed at %s\n\n", version, time.Now().UTC().Format(time.RFC3339))
+	fmt.Fprintf(&sb, "panic: %v\n\n", r)
+	if current.file != "" {
+		fmt.Fprintf(&sb, "reading: %s record %d\n", current.file, current.record)
+	}
+	if key != "" {
+		fmt.Fprintf(&sb, "writing key: %q\n", policyKey(key))
+	}
+	sb.WriteString("\n")
+	fmt.Fprintf(&sb, "inputs: %s\n", strings.Join(inputNames, ", "))
+	sb.WriteString("options:\n")
+	flag.Visit(func(f *flag.Flag) {
+		fmt.Fprintf(&sb, "  %s: %s\n", f.Name, redact(f.Name, f.Value.String()))
+	})
+	fmt.Fprintf(&sb, "\n%s", stack)
+
+	name := filepath.Join(*crashDir, fmt.Sprintf("csvjoin-crash-%s-%d.txt", time.Now().UTC().Format("20060102T150405"), os.Getpid()))
+	if err := os.WriteFile(name, []byte(sb.String()), 0600); err != nil {
+		log.Printf("csvjoin crashed and could not write a crash report: %v", err)
+		os.Stderr.WriteString(sb.String())
+	} else {
+		log.Printf("csvjoin crashed: %v. a crash report was written to %s; please include it in a bug report", r, name)
+	}
+
+	AbandonOutputs()
+	RemoveSnapshots()
+	os.Exit(2)
+}
+
+// redact hides option values that may hold secrets: named secret options,
+// and URLs carrying credentials or query parameters.
+func redact(name, value string) string {
+
+	if secretFlags[name] {
+		return "(redacted)"
+	}
+
+	if u, err := url.Parse(value); err == nil && u.Host != "" && (u.User != nil || u.RawQuery != "") {
+		return u.Scheme + "://" + u.Host + "/(redacted)"
+	}
+
+	return value
+}
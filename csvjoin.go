@@ -2,133 +2,429 @@ package main
 
 import (
 	"encoding/csv"
+	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
-	"sort"
+	"path/filepath"
 	"strings"
+	"time"
 )
 
 var (
-	writer *csv.Writer
+	writer RowWriter
+
+	crlf  = flag.Bool("crlf", defaultCRLF, "end output lines with \\r\\n (the default on Windows)")
+	on    = flag.String("on", "", "comma separated join columns. by default the columns common to all inputs are used")
+	notOn = flag.String("not-on", "", "comma separated columns to leave out of the auto-detected join columns")
+
+	cross   = flag.Bool("cross", false, "when the inputs share no columns, output every combination of their rows (a cross join) instead of failing")
+	emitKey = flag.String("emit-key", "", "add an output column with this name holding each row's join key: the normalized join column values joined with --key-sep")
+
+	// keyColumns, when set, names the join columns instead of detecting them
+	// from the headers. It starts out as --on.
+	keyColumns []string
+
+	// inputNames are the names of the inputs being joined, for messages.
+	inputNames []string
+
+	// rowLimit, when positive, stops output after that many rows.
+	rowLimit    int
+	rowsWritten int
 )
 
 func main() {
 
+	defer RecoverCrash()
+
+	if startBrowser() {
+		return
+	}
+
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "repl":
+			Repl(os.Args[2:])
+			return
+		case "gen":
+			Gen(os.Args[2:])
+			return
+		case "check-compat":
+			CheckCompat(os.Args[2:])
+			return
+		case "bench":
+			Bench(os.Args[2:])
+			return
+		}
+	}
+
+	flag.Parse()
+	LoadConfig()
+	ApplyProfile()
+	ApplyOn()
+	SetMemoryLimit()
+
 	fileNames := GetFileNames()
+	SnapshotInputs(fileNames)
+	CheckInputAges(fileNames)
+	VerifyInputs(fileNames)
+	Prescan(fileNames)
 	readers := OpenReaders(fileNames)
+
+	dest := CreateOutput(*outputFile)
+	out := NewOutputEncoder(dest)
+	var sink RowWriter = &rowCounter{w: out, file: dest}
+	sheet := NewSheetWriter()
+	if sheet != nil {
+		sink = sheet
+	}
+	ow := NewOutputWriter(sink)
+	if *headerComments && sheet == nil {
+		ow.Preamble = func() error {
+			return WriteHeaderComments(dest, fileNames)
+		}
+	}
+	Run(readers, fileNames, ow)
+	RemoveSnapshots()
+
+	ow.Flush()
+	out.Flush()
+	err := out.Error()
+	if err == nil {
+		err = dest.Close()
+	}
+	if err != nil {
+		fail(&RunError{Class: errOutput, File: dest.Name, Message: fmt.Sprintf("failed to write CSV output: %v", err)})
+	}
+	if sheet != nil {
+		sheet.Upload()
+	}
+
+	if *saveSpec != "" {
+		WriteSpec(*saveSpec, fileNames)
+	}
+
+	WriteManifest()
+	NotifyWebhook(nil)
+}
+
+// RowReader is a source of CSV rows. *csv.Reader satisfies it.
+type RowReader interface {
+	Read() ([]string, error)
+}
+
+// RowWriter is a destination for CSV rows. *csv.Writer satisfies it.
+type RowWriter interface {
+	Write(row []string) error
+}
+
+// Run performs the join the command line options ask for: a --pipeline or
+// --plan, or a single join of all the inputs.
+func Run(readers []RowReader, fileNames []string, w RowWriter) {
+
+	drops = nil
+	outlierStats = nil
+
+	switch {
+	case *pipelineOpt != "" || *planOpt != "":
+		RunPipeline(readers, fileNames, w)
+	case *geoJoinOpt != "":
+		GeoJoin(readers, fileNames, w)
+	case *rangeOpt != "":
+		RangeJoin(readers, fileNames, w)
+	case *hierarchyOpt != "":
+		HierarchyJoin(readers, fileNames, w)
+	default:
+		Join(readers, fileNames, w)
+	}
+
+	ReportDrops()
+}
+
+// Join reads all the input sources and writes the header and joined rows to
+// w.
+func Join(readers []RowReader, fileNames []string, w RowWriter) {
+
+	inputNames = fileNames
+
 	allHeaders := GatherAllHeaders(readers, fileNames)
-	joinColumns := IdentifyJoinColumns(allHeaders)
-	outputColumns := IdentifyOutputColumns(allHeaders)
+	ResolveBuckets(fileNames, allHeaders)
+	ResolveTombstones(fileNames, allHeaders)
+	joinColumns := IdentifyJoinColumns(allHeaders, fileNames)
+	joinColumns = AsOfJoinColumns(joinColumns, allHeaders, fileNames)
+	joinColumns = SuggestKey(readers, allHeaders, joinColumns)
+	outputColumns := JoinOutputColumns(allHeaders, IdentifyOutputColumns(allHeaders))
+	resolvedJoinColumns = joinColumns
+	CheckKeyPolicy(joinColumns)
 
 	allKeys, allData := ReadAllInputSources(readers, allHeaders, joinColumns)
+	WriteKeyAudit()
+	ReleaseScratch()
 
-	writer = csv.NewWriter(os.Stdout)
+	if *venn {
+		WriteVenn(allKeys, allData, fileNames, w)
+		return
+	}
+	allData = ChooseJoinOrder(allData)
+
+	writer = w
+	rowsWritten = 0
+	rowPooling = poolingFor(w)
+
+	header := outputColumns
+	if markingDeletes() {
+		header = append(header[:len(header):len(header)], "deleted")
+	}
+	if *emitKey != "" {
+		if contains(header, *emitKey) {
+			usagef("--emit-key %s is already an output column", *emitKey)
+		}
+		header = append(header[:len(header):len(header)], *emitKey)
+	}
 
-	err := writer.Write(outputColumns)
+	err := writer.Write(header)
 	if err != nil {
-		log.Fatalf("failed to write CSV output: %v", err)
+		fail(&RunError{Class: errOutput, Message: fmt.Sprintf("failed to write CSV output: %v", err)})
 	}
 
-	for _, key := range allKeys {
-		WriteCSVs(key, outputColumns, allData)
+	OpenSkippedKeys()
+
+	if *parallel > 1 {
+		WriteKeysParallel(allKeys, outputColumns, allData, *parallel)
+	} else {
+		for _, key := range allKeys {
+			if rowLimit > 0 && rowsWritten >= rowLimit {
+				break
+			}
+			if keyDeleted(key) {
+				dropKeyRows(key, allData, "key deleted by a tombstone")
+				releaseKey(key, allData)
+				continue
+			}
+			WriteCSVs(key, outputColumns, allData)
+			releaseKey(key, allData)
+		}
 	}
 
-	writer.Flush()
+	CloseSkippedKeys()
+	ReportDeletedKeys()
 }
 
 // WriteCSVs writes out the full join of records across all the data collections
 // for a single key.
 func WriteCSVs(key string, outputColumns []string, allData []DataCollection) {
 
-	prt := func(recs []Record) {
+	current.key = key
 
-		row := []string{}
+	limit := 0
+	if rowLimit > 0 {
+		limit = rowLimit - rowsWritten
+	}
 
-		for _, col := range outputColumns {
-			got := false
-			for _, rec := range recs {
-				v, ok := rec[col]
-				if ok {
-					row = append(row, v)
-					got = true
-					break
-				}
-			}
-			if !got {
-				row = append(row, "")
-			}
+	if !KeyRows(key, outputColumns, allData, limit, WriteRow) {
+		dropKeyRows(key, allData, "key skipped by --key-timeout")
+		SkipKey(key)
+	}
+}
+
+// KeyRows produces the output rows for a single key, passing each to emit,
+// stopping after limit rows if limit is positive. With --key-timeout, the
+// key's rows are held back until all of them have been produced, so that a
+// key which runs out of time can be skipped cleanly; KeyRows then returns
+// false having emitted nothing. It touches no shared state, so keys can be
+// worked on in parallel.
+func KeyRows(key string, outputColumns []string, allData []DataCollection, limit int, emit func([]string)) bool {
+
+	deadline := keyDeadline()
+
+	pending := [][]string{}
+	emitted := 0
+	timedOut := false
+
+	prt := func(recs []Record) bool {
+
+		if limit > 0 && emitted >= limit {
+			return false
 		}
+		emitted++
 
-		err := writer.Write(row)
-		if err != nil {
-			log.Fatalf("failed to write CSV output: %v", err)
+		row := BuildRow(outputColumns, restoreOrder(recs))
+		if markingDeletes() {
+			row = append(row, boolString(deletedKeys[key]))
+		}
+		if *emitKey != "" {
+			row = append(row, shownKey(key))
+		}
+
+		if deadline.IsZero() {
+			emit(row)
+			return true
+		}
+
+		if time.Now().After(deadline) {
+			timedOut = true
+			return false
+		}
+
+		pending = append(pending, row)
+		return true
+	}
+
+	switch {
+	case *joinMode == "semi":
+		semiRows(key, allData, prt)
+	case *asOfCol != "":
+		asOfRows(key, allData, prt)
+	default:
+		recurse(key, []Record{}, allData, prt)
+	}
+
+	if timedOut {
+		return false
+	}
+
+	for _, row := range pending {
+		emit(row)
+	}
+
+	return true
+}
+
+// BuildRow builds an output row from a combination of records. Where several
+// records have the same column, the first one's value is used.
+func BuildRow(outputColumns []string, recs []Record) []string {
+
+	row := newRow(len(outputColumns) + 1)
+
+	for _, col := range outputColumns {
+		got := false
+		for _, rec := range recs {
+			v, ok := rec[col]
+			if ok {
+				row = append(row, v)
+				got = true
+				break
+			}
+		}
+		if !got {
+			row = append(row, "")
 		}
 	}
 
-	recurse(key, []Record{}, allData, prt)
+	return row
 }
 
-// Printer is a function that prints a record from a slice of Records.
-type Printer func([]Record)
+// WriteRow writes an output row.
+func WriteRow(row []string) {
+
+	err := writer.Write(row)
+	if err != nil {
+		fail(&RunError{Class: errOutput, Message: fmt.Sprintf("failed to write CSV output: %v", err)})
+	}
+	rowsWritten++
+	putRow(row)
+}
+
+// Printer is a function that prints a record from a slice of Records. It
+// returns false to stop the iteration.
+type Printer func([]Record) bool
 
 // recurse is a recurser to iterate over all the combinations of Records for a
-// particular key.
-func recurse(key string, recs []Record, remain []DataCollection, prt Printer) {
+// particular key. A collection with no records for the key contributes a nil
+// Record, so that recs always has one entry per collection. Returns false if
+// the Printer stopped the iteration.
+func recurse(key string, recs []Record, remain []DataCollection, prt Printer) bool {
 
 	if len(remain) == 0 {
-		prt(recs)
-		return
+		return prt(recs)
 	}
 
 	this := remain[0]
 	thisRecords := this.data[key]
 
 	if len(thisRecords) == 0 {
-		recurse(key, recs, remain[1:], prt)
-		return
+		return recurse(key, append(recs, nil), remain[1:], prt)
 	}
 
 	for _, rec := range thisRecords {
-		recurse(key, append(recs, rec), remain[1:], prt)
+		if !recurse(key, append(recs, rec), remain[1:], prt) {
+			return false
+		}
 	}
+
+	return true
 }
 
 // ReadAllInputSources reads all the readers, loading all data into
 // DataCollections. Returns a list of distinct keys (across all inputs), and a
 // list of all the DataCollections.
-func ReadAllInputSources(readers []*csv.Reader, allHeaders [][]string, joinColumns []string) ([]string, []DataCollection) {
+func ReadAllInputSources(readers []RowReader, allHeaders [][]string, joinColumns []string) ([]string, []DataCollection) {
 
-	keyMap := map[string]bool{}
+	checkJoinMode()
+	checkEmptyKeys()
+
+	// keyMap counts the inputs each key appears in.
+	keyMap := map[string]int{}
 	allData := []DataCollection{}
+	scientificKeys = 0
 
 	for i, r := range readers {
 
-		data := ReadData(r, allHeaders[i], joinColumns)
+		data := ReadData(r, allHeaders[i], joinColumns, i)
+		releaseReader(readers, i)
+
+		allData = append(allData, data)
+	}
+
+	if *fuzzy > 0 {
+		allData = FuzzyMerge(allData, *fuzzy)
+	}
+	allData = PrefixMerge(allData, joinColumns)
+	CollapseInputs(allData, allHeaders, inputNames)
+	SortAsOf(allData)
+	CheckExpectation(allData)
+	CheckMatchRates(allData)
 
+	for _, data := range allData {
 		for k := range data.data {
-			keyMap[k] = true
+			keyMap[k]++
 		}
+	}
 
-		allData = append(allData, data)
+	if scientificKeys > 0 {
+		log.Printf("expanded %d join key values from scientific notation", scientificKeys)
 	}
 
-	keys := []string{}
+	keys := make([]string, 0, len(keyMap))
 	for k := range keyMap {
 		keys = append(keys, k)
 	}
-	sort.Strings(keys)
+	SortKeys(keys)
+
+	kept := keys[:0]
+	for _, k := range keys {
+		if !keepKey(len(allData[0].data[k]) > 0, keyMap[k], len(allData)) {
+			for i, dc := range allData {
+				CountDrop(inputNames[i], joinDropReason(), len(dc.data[k]))
+			}
+			continue
+		}
+		kept = append(kept, k)
+	}
+	keys = kept
+	runKeys = len(keys)
 
 	return keys, allData
 }
 
 // ReadData reads a CSV input source collecting all the input into a DataCollection.
-func ReadData(reader *csv.Reader, headers []string, joinColumns []string) DataCollection {
+// src is the position of the source in inputNames.
+func ReadData(reader RowReader, headers []string, joinColumns []string, src int) DataCollection {
 
 	recordOf := func(row []string) Record {
 
-		r := Record{}
+		r := newRecord(len(headers))
 
 		for i, v := range row {
 			n := headers[i]
@@ -138,48 +434,206 @@ func ReadData(reader *csv.Reader, headers []string, joinColumns []string) DataCo
 		return r
 	}
 
-	keyOf := func(rec Record) string {
+	normalize := KeyNormalizer()
+	transform := KeyTransformer()
+	keyDates := KeyDates()
+	keyPads := KeyPads()
+	coerce := NewCoercion(inputNames[src])
+	recNum := 0
+	var offset int64
+
+	// unreadable handles a value of column c that can't be read as the type
+	// the options give it, returning true if the record is to be skipped.
+	unreadable := func(c, v, as, hint string) bool {
+		line, col := fieldPos(reader, indexOf(headers, c))
+		return coerce.Failed(c, &RunError{
+			Class:   errParse,
+			File:    inputNames[src],
+			Line:    line,
+			Column:  col,
+			Record:  recNum,
+			Offset:  offset,
+			Message: fmt.Sprintf("%s record %d: cannot read %s value %q as %s", inputNames[src], recNum, c, v, as),
+			Hint:    hint,
+		})
+	}
+
+	// keyOf returns the record's key, or false if the record is to be
+	// skipped.
+	keyOf := func(rec Record) (string, bool) {
 
 		sb := strings.Builder{}
 
 		for i, c := range joinColumns {
 			if i > 0 {
-				sb.WriteString("++")
+				sb.WriteString(*keySep)
 			}
-			sb.WriteString(rec[c])
+			v := transform(c, rec[c])
+			if layouts, ok := keyDates[c]; ok {
+				d, ok := dateKey(v, layouts)
+				if !ok {
+					if unreadable(c, v, "a date", "--key-date "+c+" values must match one of its layouts") {
+						return "", false
+					}
+					d = ""
+				}
+				v = d
+			}
+			v = normalize(v)
+			if p, ok := keyPads[c]; ok {
+				v = p.apply(v)
+			}
+			if *keyType == "numeric" {
+				n, ok := numericKey(v)
+				if !ok {
+					if unreadable(c, v, "a number", "--key-type numeric needs every join column value to be a number") {
+						return "", false
+					}
+					n = ""
+				}
+				v = n
+			}
+			sb.WriteString(keyPart(v))
 		}
 
-		return sb.String()
+		if len(buckets) > 0 {
+			b := buckets[src]
+			bucket, ok := bucketOf(rec[b.column], b.unit)
+			if !ok && unreadable(b.column, rec[b.column], "a time", "--bucket columns must hold timestamps such as 2006-01-02T15:04:05Z") {
+				return "", false
+			}
+			if len(joinColumns) > 0 {
+				sb.WriteString(*keySep)
+			}
+			sb.WriteString(keyPart(bucket))
+		}
+
+		return sb.String(), true
 	}
 
 	data := NewDataCollection()
+	dedupe := NewDeduper(src)
+	clean := RecordCleaner(headers)
+	validator := NewValidator(headers, inputNames[src])
+	observe := OutlierObserver(headers)
+	badRows := NewBadRows(inputNames[src])
+	reuseInputRows(reader)
 
 	for {
+		offset = inputOffset(reader)
 		row, err := reader.Read()
 		if err == io.EOF {
 			break
 		}
 		if err != nil {
-			log.Fatalf("failed to read/parse CSV input: %v", err)
+			if badRows != nil && badRows.Skip(err, recNum+1) {
+				recNum++
+				continue
+			}
+			parseFailure(inputNames[src], err, recNum+1, offset)
 		}
+		recNum++
+		current.file, current.record = inputNames[src], recNum
 
 		rec := recordOf(row)
-		key := keyOf(rec)
+		if clean != nil {
+			clean(rec)
+		}
+		if validator != nil && !validator.Check(rec, recNum) {
+			CountDrop(inputNames[src], "disallowed value", 1)
+			putRecord(rec)
+			continue
+		}
+		RepairKeys(rec, joinColumns, inputNames[src], recNum)
+		key, ok := keyOf(rec)
+		if ok && emptyKey(rec, joinColumns) {
+			key, ok = EmptyKey(key, src, recNum)
+		}
+		if !ok {
+			putRecord(rec)
+			continue
+		}
+		AuditKey(rec, joinColumns, key, src)
+
+		if IsTombstone(src, rec) {
+			deletedKeys[key] = true
+			CountDrop(inputNames[src], "tombstone record", 1)
+			putRecord(rec)
+			continue
+		}
+
+		if observe != nil {
+			observe(rec)
+		}
+
+		if dedupe != nil {
+			dedupe.Add(&data, key, row, rec)
+			continue
+		}
 
 		data.Add(key, rec)
 	}
 
+	if dedupe != nil {
+		dedupe.Report(inputNames[src])
+	}
+	coerce.Report()
+	if badRows != nil {
+		badRows.Finish(recNum)
+	}
+	if validator != nil {
+		validator.Report()
+	}
+
+	recordInputRows(src, recNum)
+	current.file, current.record = "", 0
+
 	return data
 }
 
-// GetFileNames gets the list of file names from command line arguments. If no
+// ApplyOn sets the join columns from --on.
+func ApplyOn() {
+
+	keyColumns = nil
+	if *on != "" {
+		if *notOn != "" {
+			usagef("--not-on only applies to auto-detected join columns, not with --on")
+		}
+		keyColumns = parseColumnList(*on)
+	}
+}
+
+// GetFileNames gets the list of file names from command line arguments,
+// ignoring any given twice. An input given as - is read from stdin. If no
 // files named, prints usage message and aborts program.
 func GetFileNames() []string {
 
-	fileNames := os.Args[1:]
+	fileNames := expandArgs(flag.Args())
+	if len(fileNames) == 0 {
+		fileNames = configFiles
+	}
+
+	given := len(fileNames)
+	fileNames = DedupeInputs(StdinArgs(fileNames))
+	if len(fileNames) < 2 && given >= 2 {
+		fail(&RunError{
+			Class:   errUsage,
+			Message: "fewer than two different inputs are left once duplicates are ignored",
+			Hint:    "to join a file to itself, give each copy an alias, as emp.csv:emp emp.csv:mgr",
+		})
+	}
+
+	if len(fileNames) == 1 && *hierarchyOpt != "" {
+		return fileNames
+	}
 
 	if len(fileNames) < 2 {
-		fmt.Fprintf(os.Stderr, "usage: %s f1.csv f2.csv ...\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "usage: %s [options] f1.csv f2.csv ...\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s repl [options] f1.csv f2.csv ...\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s gen [gen options]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s check-compat [--sample n] [--min-overlap pct] f1.csv f2.csv ...\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s bench [--preset wide|tall|many-files] [--rows n] [--seed n] [--dir dir]\n", os.Args[0])
+		flag.PrintDefaults()
 		os.Exit(1)
 	}
 
@@ -188,63 +642,153 @@ func GetFileNames() []string {
 
 // OpenReaders opens all the named files and creates a CSV reader for each input
 // source.
-func OpenReaders(fileNames []string) []*csv.Reader {
+func OpenReaders(fileNames []string) []RowReader {
 
-	readers := []*csv.Reader{}
+	readers := []RowReader{}
+	decompress := DecompressCommands(fileNames)
+	rateLimits := RateLimits(fileNames)
+	commas := InputDelimiters(fileNames)
+	term, terminated := InputTerminator()
 
-	for _, fName := range fileNames {
+	for i, fName := range fileNames {
 
-		r, err := os.Open(fName)
+		f, err := OpenInput(fName)
 		if err != nil {
-			log.Fatalf("cannot read CSV file %s: %v", fName, err)
+			fail(&RunError{Class: errIO, File: fName, Message: fmt.Sprintf("cannot read CSV file %s: %v", fName, err)})
+		}
+
+		var r io.Reader = f
+		if perSecond, ok := rateLimits[i]; ok {
+			r = Throttle(r, perSecond)
 		}
+		if cmd, ok := decompress[i]; ok {
+			r = Decompress(r, fName, cmd)
+		}
+		r = DecodeInput(r)
 
-		readers = append(readers, csv.NewReader(r))
+		cr := csv.NewReader(LimitReader(TerminatedReader(r), commas[i]))
+		cr.Comma = commas[i]
+		readers = append(readers, sourceReader{Reader: cr, Closer: f, term: term, terminated: terminated})
 	}
 
 	return readers
 }
 
+// FileIndex finds an input by name, as given on the command line, by alias,
+// by base name, or by base name without its extension. Returns -1 if not
+// found.
+func FileIndex(fileNames []string, name string) int {
+
+	if name == "-" {
+		name = stdinName
+	}
+
+	// An alias picks out one of several uses of the same file.
+	for i, fName := range fileNames {
+		if _, alias := splitAlias(fName); fName == name || alias == name {
+			return i
+		}
+	}
+
+	for i, fName := range fileNames {
+		path, _ := splitAlias(fName)
+		base := filepath.Base(path)
+		if path == name || base == name || strings.TrimSuffix(base, filepath.Ext(base)) == name {
+			return i
+		}
+	}
+
+	return -1
+}
+
 // GatherAllHeaders reads the firest line of each CSV reader, and returns the
 // list of all header lists.
-func GatherAllHeaders(readers []*csv.Reader, fileNames []string) [][]string {
+func GatherAllHeaders(readers []RowReader, fileNames []string) [][]string {
 
-	allHeaders := [][]string{}
+	originals, mapped := [][]string{}, [][]string{}
 
 	for i, r := range readers {
 
 		header, err := r.Read()
 		if err == io.EOF {
-			log.Fatalf("CSV file %s has no headers. cannot process.", fileNames[i])
+			fail(&RunError{
+				Class:   errInput,
+				File:    fileNames[i],
+				Message: fmt.Sprintf("CSV file %s has no headers. cannot process.", fileNames[i]),
+				Hint:    "the first line of each input must hold the column names",
+			})
 		}
+		if err != nil {
+			parseFailure(fileNames[i], err, 0, 0)
+		}
+
+		originals = append(originals, header)
+		mapped = append(mapped, MapColumns(fileNames, i, header))
+	}
 
-		allHeaders = append(allHeaders, header)
+	PositionColumns(mapped, fileNames)
+
+	allHeaders := [][]string{}
+	for i := range mapped {
+		allHeaders = append(allHeaders, AliasColumns(fileNames[i], originals[i], mapped[i]))
 	}
 
 	return allHeaders
 }
 
 // IdentifyJoinColumns looks over all the headers of all the inputs and
-// identifies which columns are in all the input sources.
-func IdentifyJoinColumns(allHeaders [][]string) []string {
+// identifies which columns are in all the input sources. If keyColumns is set,
+// those columns are used instead, after checking every input has them.
+func IdentifyJoinColumns(allHeaders [][]string, fileNames []string) []string {
+
+	if len(keyColumns) > 0 {
+		for i, header := range allHeaders {
+			for _, col := range keyColumns {
+				if !contains(header, col) {
+					fail(&RunError{Class: errSchema, File: fileNames[i], Message: fmt.Sprintf("join column %s not found in %s", col, fileNames[i])})
+				}
+			}
+		}
+		return keyColumns
+	}
 
 	headerCounts := map[string]int{}
 
 	for _, header := range allHeaders {
 		for _, col := range header {
-			headerCounts[col]++
+			if !isBucketColumn(col) {
+				headerCounts[col]++
+			}
 		}
 	}
 
+	excluded := parseColumnList(*notOn)
+
 	joinColumns := []string{}
-	for col, count := range headerCounts {
-		if count == len(allHeaders) {
+	for _, col := range allHeaders[0] {
+		if headerCounts[col] == len(allHeaders) && !contains(excluded, col) && !contains(joinColumns, col) {
 			joinColumns = append(joinColumns, col)
 		}
 	}
 
-	if len(joinColumns) == 0 {
-		log.Fatalf("cannot identify columns common to all input files to join")
+	if len(joinColumns) == 0 && len(excluded) > 0 && len(buckets) == 0 {
+		fail(&RunError{
+			Class:   errSchema,
+			Message: fmt.Sprintf("no join columns are left once --not-on %s is excluded", strings.Join(excluded, ",")),
+			Hint:    "leave fewer columns out, or name the join columns with --on",
+		})
+	}
+
+	if len(joinColumns) == 0 && len(buckets) == 0 {
+		if *cross {
+			log.Printf("the inputs share no columns. joining every row with every other (--cross)")
+			return joinColumns
+		}
+		fail(&RunError{
+			Class:   errSchema,
+			Message: "cannot identify columns common to all input files to join",
+			Hint:    "the inputs must share at least one column name, or use --cross for every combination of rows",
+		})
 	}
 
 	return joinColumns
@@ -308,3 +852,19 @@ func (u *UniqueSlice) Append(s string) {
 func (u *UniqueSlice) GetSlice() []string {
 	return u.slice
 }
+
+// contains reports whether s is in list.
+func contains(list []string, s string) bool {
+	return indexOf(list, s) >= 0
+}
+
+// indexOf returns the position of s in list, or -1.
+func indexOf(list []string, s string) int {
+	for i, x := range list {
+		if x == s {
+			return i
+		}
+	}
+
+	return -1
+}
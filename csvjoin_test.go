@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/csv"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// rowCollector is a RowWriter which keeps the rows written to it.
+type rowCollector struct {
+	rows [][]string
+}
+
+// Write appends a copy of row to the collected rows.
+func (c *rowCollector) Write(row []string) error {
+	c.rows = append(c.rows, append([]string(nil), row...))
+	return nil
+}
+
+// joinStrings runs the join over CSV documents given as strings and returns
+// the rows written.
+func joinStrings(t *testing.T, inputs ...string) [][]string {
+
+	t.Helper()
+
+	readers := []RowReader{}
+	fileNames := []string{}
+	for i, in := range inputs {
+		readers = append(readers, csv.NewReader(strings.NewReader(in)))
+		fileNames = append(fileNames, string(rune('a'+i))+".csv")
+	}
+
+	out := &rowCollector{}
+	Run(readers, fileNames, out)
+
+	return out.rows
+}
+
+// emittedKeys returns the last column of each row after the header.
+func emittedKeys(rows [][]string) []string {
+
+	keys := []string{}
+	for _, row := range rows[1:] {
+		keys = append(keys, row[len(row)-1])
+	}
+
+	return keys
+}
+
+func TestJoinColumnsFollowFirstHeader(t *testing.T) {
+
+	headers := [][]string{
+		{"e", "d", "c", "b", "a", "x"},
+		{"a", "b", "c", "d", "e", "y"},
+	}
+
+	for i := 0; i < 20; i++ {
+		got := IdentifyJoinColumns(headers, []string{"a.csv", "b.csv"})
+		want := []string{"e", "d", "c", "b", "a"}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("join columns = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestEmittedKeysRepeat(t *testing.T) {
+
+	defer func(old string) { *emitKey = old }(*emitKey)
+	*emitKey = "key"
+
+	left := "r,q,p,o,n,left\n1,2,3,4,5,l1\n6,7,8,9,0,l2\n"
+	right := "n,o,p,q,r,right\n5,4,3,2,1,r1\n0,9,8,7,6,r2\n"
+
+	first := emittedKeys(joinStrings(t, left, right))
+	if len(first) != 2 {
+		t.Fatalf("got %d joined rows, want 2", len(first))
+	}
+	for i := 0; i < 10; i++ {
+		again := emittedKeys(joinStrings(t, left, right))
+		if !reflect.DeepEqual(first, again) {
+			t.Fatalf("emitted keys changed between runs: %q then %q", first, again)
+		}
+	}
+
+	want := JoinKey([]string{"1", "2", "3", "4", "5"})
+	if !contains(first, want) {
+		t.Errorf("emitted keys %q do not include %q, in the first input's column order", first, want)
+	}
+}
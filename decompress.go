@@ -0,0 +1,71 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+var decompressOpts listFlag
+
+func init() {
+	flag.Var(&decompressOpts, "decompress", "pipe an input through an external command before parsing, as file='zstd -dc'. may be repeated")
+}
+
+// DecompressCommands returns the --decompress command for each input that has
+// one, indexed like fileNames.
+func DecompressCommands(fileNames []string) map[int][]string {
+
+	cmds := map[int][]string{}
+
+	for i, command := range perFileOptions("--decompress", decompressOpts, fileNames) {
+		if len(strings.Fields(command)) == 0 {
+			usagef("--decompress for %s has no command", fileNames[i])
+		}
+		cmds[i] = strings.Fields(command)
+	}
+
+	return cmds
+}
+
+// commandReader reads a command's output, reporting the command's failure, if
+// any, in place of EOF.
+type commandReader struct {
+	out  io.ReadCloser
+	cmd  *exec.Cmd
+	name string
+}
+
+// Read reads the command's output.
+func (c *commandReader) Read(p []byte) (int, error) {
+
+	n, err := c.out.Read(p)
+	if err == io.EOF {
+		if werr := c.cmd.Wait(); werr != nil {
+			return n, fmt.Errorf("decompress command %q failed: %v", strings.Join(c.cmd.Args, " "), werr)
+		}
+	}
+
+	return n, err
+}
+
+// Decompress starts the command with r as its input, returning its output.
+func Decompress(r io.Reader, name string, args []string) io.Reader {
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = r
+	cmd.Stderr = os.Stderr
+
+	out, err := cmd.StdoutPipe()
+	if err == nil {
+		err = cmd.Start()
+	}
+	if err != nil {
+		fail(&RunError{Class: errIO, File: name, Message: fmt.Sprintf("cannot start decompress command for %s: %v", name, err)})
+	}
+
+	return &commandReader{out: out, cmd: cmd, name: name}
+}
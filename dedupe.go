@@ -0,0 +1,81 @@
+package main
+
+import (
+	"flag"
+	"log"
+)
+
+var dedupeOpts listFlag
+
+func init() {
+	flag.Var(&dedupeOpts, "dedupe-input", "remove duplicate rows from an input before joining, as file=exact (identical rows), file=by-key:first or file=by-key:latest (one row per key). may be repeated")
+}
+
+// Deduper drops duplicate rows from one input as it is read.
+type Deduper struct {
+	mode    string
+	seen    map[string]bool
+	removed int
+}
+
+// NewDeduper returns the Deduper for input src, or nil if that input is not
+// deduplicated.
+func NewDeduper(src int) *Deduper {
+
+	mode, ok := perFileOptions("--dedupe-input", dedupeOpts, inputNames)[src]
+	if !ok {
+		return nil
+	}
+
+	switch mode {
+	case "exact", "by-key:first", "by-key:latest":
+	default:
+		usagef("--dedupe-input mode %s must be exact, by-key:first or by-key:latest", mode)
+	}
+
+	return &Deduper{mode: mode, seen: map[string]bool{}}
+}
+
+// Add adds a record to data unless it duplicates one already there.
+func (d *Deduper) Add(data *DataCollection, key string, row []string, rec Record) {
+
+	switch d.mode {
+
+	case "exact":
+		id := rowIdentity(row)
+		if d.seen[id] {
+			d.removed++
+			putRecord(rec)
+			return
+		}
+		d.seen[id] = true
+
+	case "by-key:first":
+		if len(data.data[key]) > 0 {
+			d.removed++
+			putRecord(rec)
+			return
+		}
+
+	case "by-key:latest":
+		if len(data.data[key]) > 0 {
+			// Later rows are taken to be newer, so the latest replaces the
+			// one already held.
+			d.removed++
+			putRecord(data.data[key][0])
+			data.data[key] = []Record{rec}
+			return
+		}
+	}
+
+	data.Add(key, rec)
+}
+
+// Report logs how many rows were removed.
+func (d *Deduper) Report(file string) {
+
+	if d.removed > 0 {
+		log.Printf("removed %d duplicate rows from %s (%s)", d.removed, file, d.mode)
+		CountDrop(file, "duplicate removed by --dedupe-input "+d.mode, d.removed)
+	}
+}
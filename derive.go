@@ -0,0 +1,70 @@
+package main
+
+import (
+	"flag"
+	"regexp"
+)
+
+var caseOpts listFlag
+
+func init() {
+	flag.Var(&caseOpts, "case", "add an output column computed from each row, as 'tier = when(amount>1000, \"gold\", amount>100, \"silver\", \"bronze\")'. may be repeated, and may refer to earlier --case columns")
+}
+
+var casePattern = regexp.MustCompile(`^\s*([^=\s]+)\s*=\s*(.+)$`)
+
+// Deriver computes the --case columns of output rows.
+type Deriver struct {
+	names []string
+	exprs []*Expr
+	index map[string]int
+}
+
+// NewDeriver compiles --case against the output header, returning nil if it
+// is not set.
+func NewDeriver(header []string) *Deriver {
+
+	if len(caseOpts) == 0 {
+		return nil
+	}
+
+	d := &Deriver{index: map[string]int{}}
+	columns := append([]string{}, header...)
+	for i, col := range header {
+		d.index[col] = i
+	}
+
+	for _, spec := range caseOpts {
+		m := casePattern.FindStringSubmatch(spec)
+		if m == nil {
+			usagef("--case %s must be name = expression", spec)
+		}
+		if contains(columns, m[1]) {
+			usagef("--case %s: there is already an output column %s", spec, m[1])
+		}
+		expr, err := CompileExpr(m[2], columns)
+		if err != nil {
+			usagef("--case %s: %v", m[1], err)
+		}
+		d.index[m[1]] = len(columns)
+		columns = append(columns, m[1])
+		d.names = append(d.names, m[1])
+		d.exprs = append(d.exprs, expr)
+	}
+
+	return d
+}
+
+// Derive returns the row with the --case columns added.
+func (d *Deriver) Derive(row []string) []string {
+
+	get := func(col string) string {
+		return row[d.index[col]]
+	}
+
+	for _, expr := range d.exprs {
+		row = append(row, expr.Eval(get))
+	}
+
+	return row
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"log"
+)
+
+// DropStats counts the rows of one input left out of the output for one
+// reason.
+type DropStats struct {
+	File   string `json:"file"`
+	Reason string `json:"reason"`
+	Rows   int    `json:"rows"`
+}
+
+// drops holds the rows dropped this run, in the order the reasons were first
+// met.
+var drops []*DropStats
+
+// CountDrop notes n rows of file left out of the output for reason.
+func CountDrop(file, reason string, n int) {
+
+	if n == 0 {
+		return
+	}
+
+	for _, d := range drops {
+		if d.File == file && d.Reason == reason {
+			d.Rows += n
+			return
+		}
+	}
+
+	drops = append(drops, &DropStats{File: file, Reason: reason, Rows: n})
+}
+
+// dropKeyRows counts every input row with the given key as dropped.
+func dropKeyRows(key string, allData []DataCollection, reason string) {
+
+	for k, dc := range allData {
+		src := k
+		if sourceOrder != nil {
+			src = sourceOrder[k]
+		}
+		CountDrop(inputNames[src], reason, len(dc.data[key]))
+	}
+}
+
+// ReportDrops logs the dropped row counts, per file and reason, so that an
+// output smaller than its inputs can be explained.
+func ReportDrops() {
+
+	if len(drops) == 0 {
+		return
+	}
+
+	total := 0
+	for _, d := range drops {
+		total += d.Rows
+	}
+
+	log.Printf("%d input rows were left out of the output:", total)
+	for _, d := range drops {
+		log.Printf("  %s: %d rows, %s", d.File, d.Rows, d.Reason)
+	}
+}
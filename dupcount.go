@@ -0,0 +1,80 @@
+package main
+
+import (
+	"flag"
+	"strconv"
+	"strings"
+)
+
+var countDuplicates = flag.String("count-duplicates", "", "collapse identical output rows into one, adding a column with this name holding the number of copies")
+
+// dupCounter is a RowWriter that collapses identical rows, writing each
+// distinct row once with its count when finished.
+type dupCounter struct {
+	w      RowWriter
+	column string
+	header bool
+	rows   [][]string
+	counts map[string]int
+	index  map[string]int
+}
+
+// NewDupCounter wraps w to count duplicate rows.
+func NewDupCounter(w RowWriter, column string) *dupCounter {
+	return &dupCounter{
+		w:      w,
+		column: column,
+		counts: map[string]int{},
+		index:  map[string]int{},
+	}
+}
+
+// Write passes the header through, with the count column added, and holds
+// back other rows.
+func (d *dupCounter) Write(row []string) error {
+
+	if !d.header {
+		d.header = true
+		return d.w.Write(append(append([]string{}, row...), d.column))
+	}
+
+	k := rowIdentity(row)
+	if _, seen := d.index[k]; !seen {
+		d.index[k] = len(d.rows)
+		d.rows = append(d.rows, append([]string{}, row...))
+	}
+	d.counts[k]++
+
+	return nil
+}
+
+// Flush writes each distinct row, in the order first seen, with its count.
+func (d *dupCounter) Flush() error {
+
+	for _, row := range d.rows {
+		n := d.counts[rowIdentity(row)]
+		err := d.w.Write(append(row, strconv.Itoa(n)))
+		if err != nil {
+			return err
+		}
+	}
+
+	d.rows = nil
+
+	return nil
+}
+
+// rowIdentity encodes a row as a string that is distinct for distinct rows,
+// by prefixing each value with its length.
+func rowIdentity(row []string) string {
+
+	sb := strings.Builder{}
+
+	for _, v := range row {
+		sb.WriteString(strconv.Itoa(len(v)))
+		sb.WriteByte(':')
+		sb.WriteString(v)
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+var emptyKeys = flag.String("empty-keys", "keep", "what to do with rows whose join columns are all empty: keep (join them with each other), skip (drop them) or separate (output each on its own, unjoined)")
+
+// separateKeyPrefix starts the keys given to rows kept apart by --empty-keys
+// separate. keyPart escapes every NUL and --key-sep may not contain one, so
+// no real key can start with it.
+const separateKeyPrefix = "\x00"
+
+// checkEmptyKeys fails if --empty-keys is not a known choice.
+func checkEmptyKeys() {
+
+	switch *emptyKeys {
+	case "keep", "skip", "separate":
+	default:
+		usagef("--empty-keys %s must be keep, skip or separate", *emptyKeys)
+	}
+}
+
+// emptyKey reports whether all of a record's join columns are empty.
+func emptyKey(rec Record, joinColumns []string) bool {
+
+	for _, c := range joinColumns {
+		if strings.TrimSpace(rec[c]) != "" {
+			return false
+		}
+	}
+
+	return len(joinColumns) > 0
+}
+
+// EmptyKey applies --empty-keys to a record of input src whose join columns
+// are all empty, returning the key to file it under, or false to drop it.
+func EmptyKey(key string, src int, recNum int) (string, bool) {
+
+	switch *emptyKeys {
+	case "skip":
+		CountDrop(inputNames[src], "empty join key (--empty-keys skip)", 1)
+		return "", false
+	case "separate":
+		// Padded so that the rows sort in input order.
+		return fmt.Sprintf("%s%06d:%012d", separateKeyPrefix, src, recNum), true
+	}
+
+	return key, true
+}
+
+// shownKey is a key as --emit-key outputs it: empty for a row kept apart by
+// --empty-keys separate.
+func shownKey(key string) string {
+
+	if strings.HasPrefix(key, separateKeyPrefix) {
+		return ""
+	}
+
+	return key
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+var errorJSON = flag.Bool("error-json", false, "on failure, write a final JSON object describing the error to stderr")
+
+// Error classes reported in RunError.Class.
+const (
+	errUsage  = "usage"
+	errIO     = "io"
+	errInput  = "input"
+	errParse  = "parse"
+	errSchema = "schema"
+	errOutput = "output"
+	errPolicy = "policy"
+	errOther  = "error"
+)
+
+// RunError describes a failure in enough detail for an orchestrator to act on
+// it. Line counts lines of the file, so a quoted field holding newlines spans
+// several; Record counts CSV records after the header; Offset is the byte
+// offset of the start of the record.
+type RunError struct {
+	Class   string `json:"class"`
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	Record  int    `json:"record,omitempty"`
+	Offset  int64  `json:"offset,omitempty"`
+	Message string `json:"message"`
+	Hint    string `json:"hint,omitempty"`
+}
+
+// Error returns the message.
+func (e *RunError) Error() string {
+	return e.Message
+}
+
+// Location describes where in the file the error is, as file:line:column,
+// which editors can jump to, followed by the record number and byte offset.
+// Returns "" if the position is not known.
+func (e *RunError) Location() string {
+
+	if e.File == "" || (e.Line == 0 && e.Record == 0) {
+		return ""
+	}
+
+	loc := e.File
+	if e.Line > 0 {
+		loc += fmt.Sprintf(":%d", e.Line)
+		if e.Column > 0 {
+			loc += fmt.Sprintf(":%d", e.Column)
+		}
+	}
+
+	details := []string{}
+	if e.Record > 0 {
+		details = append(details, fmt.Sprintf("record %d", e.Record))
+	}
+	if e.Record > 0 || e.Offset > 0 {
+		details = append(details, fmt.Sprintf("byte offset %d", e.Offset))
+	}
+	if len(details) > 0 {
+		loc += " (" + strings.Join(details, ", ") + ")"
+	}
+
+	return loc
+}
+
+// fail reports an unrecoverable error and exits. It is a variable so that the
+// REPL can turn failures into errors for a single command rather than ending
+// the whole session.
+var fail = func(e *RunError) {
+
+	log.Print(e.Message)
+	if loc := e.Location(); loc != "" {
+		log.Printf("at %s", loc)
+	}
+	if e.Hint != "" {
+		log.Printf("hint: %s", e.Hint)
+	}
+
+	if *errorJSON {
+		json.NewEncoder(os.Stderr).Encode(e)
+	}
+
+	NotifyWebhook(e)
+	AbandonOutputs()
+	RemoveSnapshots()
+
+	os.Exit(1)
+}
+
+// fatalf fails with a general error.
+func fatalf(format string, v ...interface{}) {
+	fail(&RunError{Class: errOther, Message: fmt.Sprintf(format, v...)})
+}
+
+// usagef fails because of a bad command line option.
+func usagef(format string, v ...interface{}) {
+	fail(&RunError{Class: errUsage, Message: fmt.Sprintf(format, v...), Hint: "run with -help to list the options"})
+}
+
+// parseFailure fails because the input file could not be parsed, picking out
+// the location from a csv.ParseError. record and offset are the number and
+// starting byte offset of the record being read, 0 if not known.
+func parseFailure(file string, err error, record int, offset int64) {
+
+	e := &RunError{
+		Class:   errParse,
+		File:    file,
+		Record:  record,
+		Offset:  offset,
+		Message: fmt.Sprintf("failed to read/parse CSV input %s: %v", file, err),
+	}
+
+	var pe *csv.ParseError
+	if errors.As(err, &pe) {
+		e.Line = pe.Line
+		e.Column = pe.Column
+		switch {
+		case errors.Is(pe.Err, csv.ErrFieldCount):
+			e.Hint = "every row must have the same number of fields as the header"
+		case errors.Is(pe.Err, csv.ErrQuote), errors.Is(pe.Err, csv.ErrBareQuote):
+			e.Hint = "check for an unbalanced or stray double quote"
+		}
+	}
+
+	var se *sizeError
+	if errors.As(err, &se) {
+		e.Class = errInput
+		e.Line = se.line
+		e.Offset = se.offset
+		e.Message = fmt.Sprintf("%s: %v", file, se)
+		e.Hint = "a missing closing quote can swallow the rest of the file into one field"
+	}
+
+	fail(e)
+}
+
+// fieldPos returns the line and column of a field of the last row read, if
+// the reader can tell.
+func fieldPos(reader RowReader, field int) (int, int) {
+
+	if p, ok := reader.(interface{ FieldPos(int) (int, int) }); ok {
+		return p.FieldPos(field)
+	}
+
+	return 0, 0
+}
+
+// inputOffset returns the byte offset the reader has read to, if it can
+// tell. Taken before a Read, it is the offset at which the record starts.
+func inputOffset(reader RowReader) int64 {
+
+	if p, ok := reader.(interface{ InputOffset() int64 }); ok {
+		return p.InputOffset()
+	}
+
+	return 0
+}
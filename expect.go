@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+)
+
+var expectOpt = flag.String("expect", "", "fail unless keys match as expected: 1:1 (no key repeats within any input), 1:m (keys are unique in the first input) or m:1 (keys are unique in every input but the first)")
+
+// CheckExpectation fails the run if any key breaks --expect, naming the
+// input and one such key, before any rows are combined.
+func CheckExpectation(allData []DataCollection) {
+
+	if *expectOpt == "" {
+		return
+	}
+
+	switch *expectOpt {
+	case "1:1", "1:m", "m:1":
+	default:
+		usagef("--expect %s must be 1:1, 1:m or m:1", *expectOpt)
+	}
+
+	// unique reports whether input i must not repeat a key.
+	unique := func(i int) bool {
+		switch *expectOpt {
+		case "1:m":
+			return i == 0
+		case "m:1":
+			return i > 0
+		}
+		return true
+	}
+
+	for i, dc := range allData {
+
+		if !unique(i) {
+			continue
+		}
+
+		repeated := []string{}
+		for key, recs := range dc.data {
+			if len(recs) > 1 {
+				repeated = append(repeated, key)
+			}
+		}
+		if len(repeated) == 0 {
+			continue
+		}
+		sort.Strings(repeated)
+
+		fail(&RunError{
+			Class:   errPolicy,
+			File:    inputNames[i],
+			Message: fmt.Sprintf("--expect %s: %d keys have more than one row in %s, such as %q with %d rows", *expectOpt, len(repeated), inputNames[i], repeated[0], len(dc.data[repeated[0]])),
+			Hint:    "check the join columns, or use --dedupe-input or --collapse to combine the repeated rows",
+		})
+	}
+}
@@ -0,0 +1,436 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Expr is a compiled expression over the columns of a row, such as
+// amount > 100 && status != 'closed'. Values are strings; comparisons are
+// numeric when both sides are numbers and textual otherwise.
+// when(c1, v1, c2, v2, ..., otherwise) is the value for the first true
+// condition, or otherwise ("" if it is left out).
+type Expr struct {
+	src  string
+	root exprNode
+}
+
+type exprNode interface {
+	eval(get func(col string) string) string
+}
+
+type exprLiteral struct {
+	value string
+}
+
+type exprColumn struct {
+	name string
+}
+
+type exprNot struct {
+	x exprNode
+}
+
+type exprBinary struct {
+	op   string
+	l, r exprNode
+}
+
+type exprWhen struct {
+	conds, values []exprNode
+	otherwise     exprNode
+}
+
+func (e exprLiteral) eval(get func(string) string) string {
+	return e.value
+}
+
+func (e exprColumn) eval(get func(string) string) string {
+	return get(e.name)
+}
+
+func (e exprNot) eval(get func(string) string) string {
+	return boolString(!truthy(e.x.eval(get)))
+}
+
+func (e exprWhen) eval(get func(string) string) string {
+
+	for i, cond := range e.conds {
+		if truthy(cond.eval(get)) {
+			return e.values[i].eval(get)
+		}
+	}
+
+	if e.otherwise == nil {
+		return ""
+	}
+
+	return e.otherwise.eval(get)
+}
+
+func (e exprBinary) eval(get func(string) string) string {
+
+	switch e.op {
+	case "&&":
+		return boolString(truthy(e.l.eval(get)) && truthy(e.r.eval(get)))
+	case "||":
+		return boolString(truthy(e.l.eval(get)) || truthy(e.r.eval(get)))
+	}
+
+	c := compareValues(e.l.eval(get), e.r.eval(get))
+
+	switch e.op {
+	case "=", "==":
+		return boolString(c == 0)
+	case "!=":
+		return boolString(c != 0)
+	case "<":
+		return boolString(c < 0)
+	case "<=":
+		return boolString(c <= 0)
+	case ">":
+		return boolString(c > 0)
+	default:
+		return boolString(c >= 0)
+	}
+}
+
+// truthy reports whether a value counts as true: anything but "", "0" and
+// "false".
+func truthy(v string) bool {
+	return v != "" && v != "0" && v != "false"
+}
+
+func boolString(b bool) string {
+	if b {
+		return "true"
+	}
+	return "false"
+}
+
+// compareValues compares numerically if both values are numbers, and as
+// strings otherwise.
+func compareValues(a, b string) int {
+
+	x, okA := parseNumber(a)
+	y, okB := parseNumber(b)
+
+	if okA && okB {
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	}
+
+	return strings.Compare(a, b)
+}
+
+// CompileExpr parses an expression, checking that every column it names is in
+// columns.
+func CompileExpr(src string, columns []string) (*Expr, error) {
+
+	tokens, err := tokenizeExpr(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &exprParser{tokens: tokens, columns: columns}
+
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q in %q", p.tokens[p.pos].text, src)
+	}
+
+	return &Expr{src: src, root: root}, nil
+}
+
+// Eval evaluates the expression, looking up column values with get.
+func (e *Expr) Eval(get func(col string) string) string {
+	return e.root.eval(get)
+}
+
+// Match reports whether the expression is true.
+func (e *Expr) Match(get func(col string) string) bool {
+	return truthy(e.Eval(get))
+}
+
+// String returns the expression source.
+func (e *Expr) String() string {
+	return e.src
+}
+
+const (
+	tokIdent = iota
+	tokString
+	tokNumber
+	tokOp
+)
+
+type exprToken struct {
+	kind int
+	text string
+}
+
+// tokenizeExpr splits an expression into identifiers, quoted strings,
+// numbers and operators. `back quotes` allow column names with spaces.
+func tokenizeExpr(src string) ([]exprToken, error) {
+
+	tokens := []exprToken{}
+	rs := []rune(src)
+
+	for i := 0; i < len(rs); {
+
+		c := rs[i]
+
+		switch {
+		case unicode.IsSpace(c):
+			i++
+
+		case c == '\'' || c == '"' || c == '`':
+			j := i + 1
+			for j < len(rs) && rs[j] != c {
+				j++
+			}
+			if j >= len(rs) {
+				return nil, fmt.Errorf("unterminated quote in %q", src)
+			}
+			kind := tokString
+			if c == '`' {
+				kind = tokIdent
+			}
+			tokens = append(tokens, exprToken{kind, string(rs[i+1 : j])})
+			i = j + 1
+
+		case unicode.IsDigit(c) || c == '.' || (c == '-' && i+1 < len(rs) && unicode.IsDigit(rs[i+1]) && expectsOperand(tokens)):
+			j := i + 1
+			for j < len(rs) && (unicode.IsDigit(rs[j]) || rs[j] == '.' || rs[j] == 'e' || rs[j] == 'E') {
+				j++
+			}
+			tokens = append(tokens, exprToken{tokNumber, string(rs[i:j])})
+			i = j
+
+		case unicode.IsLetter(c) || c == '_':
+			j := i + 1
+			for j < len(rs) && (unicode.IsLetter(rs[j]) || unicode.IsDigit(rs[j]) || rs[j] == '_' || rs[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, exprToken{tokIdent, string(rs[i:j])})
+			i = j
+
+		default:
+			op := ""
+			for _, candidate := range []string{"&&", "||", "==", "!=", "<=", ">=", "=", "<", ">", "!", "(", ")", ","} {
+				if strings.HasPrefix(string(rs[i:]), candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected %q in %q", string(c), src)
+			}
+			tokens = append(tokens, exprToken{tokOp, op})
+			i += len(op)
+		}
+	}
+
+	return tokens, nil
+}
+
+// expectsOperand reports whether the next token should be a value rather than
+// an operator, so that a '-' there is a sign.
+func expectsOperand(tokens []exprToken) bool {
+
+	if len(tokens) == 0 {
+		return true
+	}
+
+	last := tokens[len(tokens)-1]
+
+	return last.kind == tokOp && last.text != ")"
+}
+
+type exprParser struct {
+	tokens  []exprToken
+	pos     int
+	columns []string
+}
+
+func (p *exprParser) peek() (exprToken, bool) {
+	if p.pos >= len(p.tokens) {
+		return exprToken{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+func (p *exprParser) acceptOp(ops ...string) (string, bool) {
+
+	t, ok := p.peek()
+	if !ok || t.kind != tokOp {
+		return "", false
+	}
+
+	for _, op := range ops {
+		if t.text == op {
+			p.pos++
+			return op, true
+		}
+	}
+
+	return "", false
+}
+
+func (p *exprParser) parseOr() (exprNode, error) {
+
+	l, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		if _, ok := p.acceptOp("||"); !ok {
+			return l, nil
+		}
+		r, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l = exprBinary{op: "||", l: l, r: r}
+	}
+}
+
+func (p *exprParser) parseAnd() (exprNode, error) {
+
+	l, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		if _, ok := p.acceptOp("&&"); !ok {
+			return l, nil
+		}
+		r, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		l = exprBinary{op: "&&", l: l, r: r}
+	}
+}
+
+func (p *exprParser) parseNot() (exprNode, error) {
+
+	if _, ok := p.acceptOp("!"); ok {
+		x, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return exprNot{x: x}, nil
+	}
+
+	return p.parseCompare()
+}
+
+func (p *exprParser) parseCompare() (exprNode, error) {
+
+	l, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+
+	op, ok := p.acceptOp("==", "=", "!=", "<=", ">=", "<", ">")
+	if !ok {
+		return l, nil
+	}
+
+	r, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+
+	return exprBinary{op: op, l: l, r: r}, nil
+}
+
+func (p *exprParser) parseValue() (exprNode, error) {
+
+	t, ok := p.peek()
+	if !ok {
+		return nil, fmt.Errorf("expression ends too soon")
+	}
+	p.pos++
+
+	switch t.kind {
+	case tokString, tokNumber:
+		return exprLiteral{value: t.text}, nil
+
+	case tokIdent:
+		if t.text == "true" || t.text == "false" {
+			return exprLiteral{value: t.text}, nil
+		}
+		if next, ok := p.peek(); ok && t.text == "when" && next.kind == tokOp && next.text == "(" {
+			p.pos++
+			return p.parseWhen()
+		}
+		if p.columns != nil && !contains(p.columns, t.text) {
+			return nil, fmt.Errorf("unknown column %s", t.text)
+		}
+		return exprColumn{name: t.text}, nil
+	}
+
+	if t.text == "(" {
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := p.acceptOp(")"); !ok {
+			return nil, fmt.Errorf("missing )")
+		}
+		return x, nil
+	}
+
+	return nil, fmt.Errorf("unexpected %q", t.text)
+}
+
+// parseWhen parses the arguments of when(...), after the opening (.
+func (p *exprParser) parseWhen() (exprNode, error) {
+
+	args := []exprNode{}
+
+	for {
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, x)
+
+		if _, ok := p.acceptOp(","); ok {
+			continue
+		}
+		if _, ok := p.acceptOp(")"); !ok {
+			return nil, fmt.Errorf("missing ) after when(")
+		}
+		break
+	}
+
+	if len(args) < 2 {
+		return nil, fmt.Errorf("when() needs at least a condition and a value")
+	}
+
+	w := exprWhen{}
+	if len(args)%2 == 1 {
+		w.otherwise = args[len(args)-1]
+		args = args[:len(args)-1]
+	}
+	for i := 0; i < len(args); i += 2 {
+		w.conds = append(w.conds, args[i])
+		w.values = append(w.values, args[i+1])
+	}
+
+	return w, nil
+}
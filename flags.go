@@ -0,0 +1,61 @@
+package main
+
+import (
+	"strings"
+)
+
+// listFlag is a command line option that may be given more than once,
+// collecting every value.
+type listFlag []string
+
+// String returns the values joined with commas.
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+// Set adds another value.
+func (l *listFlag) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+// Reset removes all the values.
+func (l *listFlag) Reset() {
+	*l = nil
+}
+
+// splitPair splits s at the first sep, returning ok false if sep is missing or
+// either side is empty.
+func splitPair(s, sep string) (string, string, bool) {
+
+	i := strings.Index(s, sep)
+	if i <= 0 || i+len(sep) >= len(s) {
+		return "", "", false
+	}
+
+	return s[:i], s[i+len(sep):], true
+}
+
+// perFileOptions reads repeated file=value options into a map from input
+// position to value.
+func perFileOptions(option string, specs []string, fileNames []string) map[int]string {
+
+	values := map[int]string{}
+
+	for _, spec := range specs {
+
+		file, value, ok := splitPair(spec, "=")
+		if !ok {
+			usagef("%s %s must be file=value", option, spec)
+		}
+
+		i := FileIndex(fileNames, file)
+		if i < 0 {
+			usagef("%s names %s, which is not an input file", option, file)
+		}
+
+		values[i] = value
+	}
+
+	return values
+}
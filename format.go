@@ -0,0 +1,81 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"strings"
+)
+
+var formatCols listFlag
+
+func init() {
+	flag.Var(&formatCols, "format-col", "format an output column, as col=layout: a Go time layout (date=2006-01-02) or a printf verb (amount=%.2f). may be repeated")
+}
+
+// RowFormatter builds the function that applies the --format-col options to
+// rows with the given columns, or returns nil if there are none. Values that
+// can't be read as a date or number are written unchanged.
+func RowFormatter(outputColumns []string) func(row []string) {
+
+	formats := map[int]func(string) string{}
+
+	for _, spec := range formatCols {
+
+		col, layout, ok := splitPair(spec, "=")
+		if !ok {
+			usagef("--format-col %s must be col=format", spec)
+		}
+
+		i := indexOf(outputColumns, col)
+		if i < 0 {
+			usagef("--format-col column %s is not an output column", col)
+		}
+
+		formats[i] = valueFormatter(layout)
+	}
+
+	if len(formats) == 0 {
+		return nil
+	}
+
+	return func(row []string) {
+		for i, f := range formats {
+			row[i] = f(row[i])
+		}
+	}
+}
+
+// valueFormatter returns a function formatting a single value with layout.
+func valueFormatter(layout string) func(string) string {
+
+	if !strings.Contains(layout, "%") {
+		return func(v string) string {
+			t, ok := parseTime(v)
+			if !ok {
+				return v
+			}
+			return t.Format(layout)
+		}
+	}
+
+	verb := layout[len(layout)-1]
+
+	return func(v string) string {
+
+		if verb == 's' || verb == 'q' || verb == 'v' {
+			return fmt.Sprintf(layout, v)
+		}
+
+		f, ok := parseNumber(v)
+		if !ok {
+			return v
+		}
+
+		if verb == 'd' || verb == 'x' || verb == 'X' {
+			return fmt.Sprintf(layout, int64(math.Round(f)))
+		}
+
+		return fmt.Sprintf(layout, f)
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+var maxAgeOpts listFlag
+
+func init() {
+	flag.Var(&maxAgeOpts, "max-age", "fail if an input was last modified longer ago than this, as file=24h. may be repeated")
+}
+
+// CheckInputAges fails the run if an input named by --max-age is older than
+// it allows, before anything is joined.
+func CheckInputAges(fileNames []string) {
+
+	for i, limit := range perFileOptions("--max-age", maxAgeOpts, fileNames) {
+
+		maxAge, err := time.ParseDuration(limit)
+		if err != nil || maxAge <= 0 {
+			usagef("--max-age for %s must be a duration such as 24h or 90m", fileNames[i])
+		}
+
+		if isStdin(fileNames[i]) {
+			usagef("--max-age cannot check the age of stdin")
+		}
+
+		info, err := os.Stat(inputPath(fileNames[i]))
+		if err != nil {
+			fail(&RunError{Class: errIO, File: fileNames[i], Message: fmt.Sprintf("cannot check the age of %s: %v", fileNames[i], err)})
+		}
+
+		age := time.Since(info.ModTime())
+		if age > maxAge {
+			fail(&RunError{
+				Class:   errPolicy,
+				File:    fileNames[i],
+				Message: fmt.Sprintf("%s was last modified %s ago, at %s, which is older than --max-age %s", fileNames[i], age.Round(time.Second), info.ModTime().Format(time.RFC3339), limit),
+				Hint:    "the input may be stale; refresh it or raise --max-age",
+			})
+		}
+	}
+}
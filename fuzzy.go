@@ -0,0 +1,198 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"sort"
+	"strings"
+)
+
+var fuzzy = flag.Int("fuzzy", 0, "also match join keys from different inputs within this edit (Levenshtein) distance of each other, for human entered names. matched keys are joined under the earliest input's spelling")
+
+// bkNode is a node of a BK-tree: children are keyed by their distance from
+// this node's key.
+type bkNode struct {
+	key      string
+	children map[int]*bkNode
+}
+
+// bkTree is an index of keys for finding those within an edit distance of a
+// given key without comparing it to every one.
+type bkTree struct {
+	root *bkNode
+}
+
+// Add adds a key to the tree.
+func (t *bkTree) Add(key string) {
+
+	if t.root == nil {
+		t.root = &bkNode{key: key, children: map[int]*bkNode{}}
+		return
+	}
+
+	node := t.root
+	for {
+		d := levenshtein(key, node.key)
+		if d == 0 {
+			return
+		}
+		child := node.children[d]
+		if child == nil {
+			node.children[d] = &bkNode{key: key, children: map[int]*bkNode{}}
+			return
+		}
+		node = child
+	}
+}
+
+// Within calls found for each key in the tree at most n edits from key.
+func (t *bkTree) Within(key string, n int, found func(string)) {
+
+	if t.root == nil {
+		return
+	}
+
+	pending := []*bkNode{t.root}
+	for len(pending) > 0 {
+		node := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+
+		d := levenshtein(key, node.key)
+		if d <= n {
+			found(node.key)
+		}
+
+		// By the triangle inequality, only children between d-n and d+n
+		// from this node can be within n of key.
+		for cd, child := range node.children {
+			if cd >= d-n && cd <= d+n {
+				pending = append(pending, child)
+			}
+		}
+	}
+}
+
+// levenshtein returns the number of single character insertions, deletions
+// and substitutions needed to turn a into b.
+func levenshtein(a, b string) int {
+
+	ra, rb := []rune(a), []rune(b)
+
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+
+	return prev[len(rb)]
+}
+
+// FuzzyMerge applies --fuzzy: keys of different inputs within n edits of
+// each other are treated as one key, as are any keys matched through them.
+// Each group of keys is put under the spelling from the earliest input that
+// has one, the alphabetically first if that input has several, and every
+// collection's records are moved to their group's key. Blank keys and those
+// of rows kept apart by --empty-keys separate are never matched.
+func FuzzyMerge(allData []DataCollection, n int) []DataCollection {
+
+	// first is the earliest input each key appears in.
+	first := map[string]int{}
+	inputKeys := make([][]string, len(allData))
+	trees := make([]bkTree, len(allData))
+
+	for i, dc := range allData {
+		for k := range dc.data {
+			inputKeys[i] = append(inputKeys[i], k)
+			if _, ok := first[k]; !ok {
+				first[k] = i
+			}
+		}
+		sort.Strings(inputKeys[i])
+		for _, k := range inputKeys[i] {
+			if fuzzyKey(k) {
+				trees[i].Add(k)
+			}
+		}
+	}
+
+	parent := map[string]string{}
+	var find func(string) string
+	find = func(k string) string {
+		p, ok := parent[k]
+		if !ok || p == k {
+			return k
+		}
+		root := find(p)
+		parent[k] = root
+		return root
+	}
+	earlier := func(a, b string) bool {
+		if first[a] != first[b] {
+			return first[a] < first[b]
+		}
+		return a < b
+	}
+	union := func(a, b string) {
+		ra, rb := find(a), find(b)
+		if ra == rb {
+			return
+		}
+		if earlier(rb, ra) {
+			ra, rb = rb, ra
+		}
+		parent[rb] = ra
+	}
+
+	for i, keys := range inputKeys {
+		for _, k := range keys {
+			if !fuzzyKey(k) {
+				continue
+			}
+			for j := i + 1; j < len(trees); j++ {
+				trees[j].Within(k, n, func(match string) {
+					union(k, match)
+				})
+			}
+		}
+	}
+
+	merged := 0
+	for k := range first {
+		if find(k) != k {
+			merged++
+		}
+	}
+	if merged == 0 {
+		return allData
+	}
+	log.Printf("--fuzzy %d matched %d keys to a differently spelled key", n, merged)
+
+	out := make([]DataCollection, len(allData))
+	for i, dc := range allData {
+		out[i] = NewDataCollection()
+		for _, k := range inputKeys[i] {
+			root := find(k)
+			out[i].data[root] = append(out[i].data[root], dc.data[k]...)
+		}
+	}
+
+	return out
+}
+
+// fuzzyKey reports whether --fuzzy may match a key to others: not a blank
+// key, nor one given to a row kept apart by --empty-keys separate.
+func fuzzyKey(k string) bool {
+	return k != "" && !strings.HasPrefix(k, separateKeyPrefix)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// collectionOf makes a DataCollection holding one record under each key.
+func collectionOf(keys ...string) DataCollection {
+
+	dc := NewDataCollection()
+	for _, k := range keys {
+		dc.Add(k, Record{"key": k})
+	}
+
+	return dc
+}
+
+// keysOf returns the sorted keys of a DataCollection.
+func keysOf(dc DataCollection) []string {
+
+	keys := []string{}
+	for k := range dc.data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func TestFuzzyMergeLeavesBlankKeysApart(t *testing.T) {
+
+	defer func(old string) { *emptyKeys = old }(*emptyKeys)
+	*emptyKeys = "separate"
+
+	sep0, _ := EmptyKey("", 0, 1)
+	sep1, _ := EmptyKey("", 1, 1)
+
+	allData := []DataCollection{
+		collectionOf("", sep0, "bob"),
+		collectionOf("x", sep1, "bo"),
+	}
+
+	got := FuzzyMerge(allData, 1)
+
+	if want := []string{"", sep0, "bob"}; !reflect.DeepEqual(keysOf(got[0]), want) {
+		t.Errorf("first input keys = %q, want %q", keysOf(got[0]), want)
+	}
+	if want := []string{sep1, "bob", "x"}; !reflect.DeepEqual(keysOf(got[1]), want) {
+		t.Errorf("second input keys = %q, want %q", keysOf(got[1]), want)
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"", "abc", 3},
+		{"abc", "", 3},
+		{"kitten", "sitting", 3},
+		{"flaw", "lawn", 2},
+		{"smith", "smyth", 1},
+		{"müller", "muller", 1},
+		{"abc", "abc", 0},
+		{"ab", "ba", 2},
+	}
+
+	for _, tt := range tests {
+		if got := levenshtein(tt.a, tt.b); got != tt.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestBKTreeWithin(t *testing.T) {
+
+	words := []string{
+		"smith", "smyth", "smithe", "schmidt", "smit", "jones", "johns", "jonas",
+		"brown", "browne", "braun", "", "a", "ab", "müller", "muller", "mueller",
+		"smith", // added twice, found once
+	}
+
+	tree := bkTree{}
+	for _, w := range words {
+		tree.Add(w)
+	}
+
+	for _, query := range []string{"smith", "jone", "brwn", "", "x", "müler", "zzzzzzz"} {
+		for n := 0; n <= 3; n++ {
+			want := map[string]bool{}
+			for _, w := range words {
+				if levenshtein(query, w) <= n {
+					want[w] = true
+				}
+			}
+
+			got := map[string]bool{}
+			tree.Within(query, n, func(k string) {
+				if got[k] {
+					t.Errorf("Within(%q, %d) found %q twice", query, n, k)
+				}
+				got[k] = true
+			})
+
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Within(%q, %d) = %v, want %v", query, n, got, want)
+			}
+		}
+	}
+}
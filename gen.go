@@ -0,0 +1,232 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GenColumn describes one generated column.
+type GenColumn struct {
+	Name string
+	Type string
+}
+
+// GenSchema describes the fixtures to generate: a key column shared by every
+// file and the other columns each file carries.
+type GenSchema struct {
+	Key     string
+	Columns []GenColumn
+}
+
+// defaultGenSchema is used when no --schema file is given.
+var defaultGenSchema = GenSchema{
+	Key: "id",
+	Columns: []GenColumn{
+		{Name: "name", Type: "string"},
+		{Name: "amount", Type: "float"},
+		{Name: "created", Type: "date"},
+	},
+}
+
+var genWords = []string{"alpha", "bravo", "charlie", "delta", "echo", "foxtrot", "golf", "hotel", "india", "juliet"}
+
+// Gen implements the gen subcommand, writing synthetic joinable CSV files.
+func Gen(args []string) {
+
+	fs := flag.NewFlagSet("gen", flag.ExitOnError)
+	rows := fs.Float64("rows", 1000, "rows per file (1e6 style values are accepted)")
+	files := fs.Int("files", 2, "number of files to generate")
+	schemaFile := fs.String("schema", "", "YAML schema file naming the key and columns to generate")
+	overlap := fs.Float64("overlap", 0.7, "fraction of each later file's keys that also appear in the first file")
+	dups := fs.Float64("dups", 0, "fraction of rows that repeat a key already in the same file")
+	dirty := fs.Float64("dirty", 0, "fraction of values that are made dirty (padding, case, blanks, stray quotes)")
+	out := fs.String("out", "fixture", "output file name prefix; files are written as <prefix>1.csv, <prefix>2.csv, ...")
+	genSeed := fs.Int64("seed", 1, "seed for the generated values. the same seed and options make the same files. 0 picks a seed from the clock and logs it")
+	fs.Parse(args)
+
+	if *files < 1 || *rows < 0 {
+		usagef("gen needs at least one file and a non-negative row count")
+	}
+	if *overlap < 0 || *overlap > 1 || *dups < 0 || *dups >= 1 || *dirty < 0 || *dirty > 1 {
+		usagef("gen --overlap and --dirty must be in [0,1], --dups in [0,1)")
+	}
+
+	schema := defaultGenSchema
+	if *schemaFile != "" {
+		schema = ReadGenSchema(*schemaFile)
+	}
+
+	g := &generator{
+		rnd:    seededRand("gen --seed", *genSeed),
+		schema: schema,
+		rows:   int(*rows),
+		dups:   *dups,
+		dirty:  *dirty,
+	}
+
+	for i := 1; i <= *files; i++ {
+
+		firstKey := 0
+		if i > 1 {
+			// Start later files part way into the first file's key range so
+			// that the requested fraction of their keys overlap it.
+			firstKey = int(float64(g.rows) * (1 - *overlap))
+		}
+
+		fName := fmt.Sprintf("%s%d.csv", *out, i)
+		g.writeFile(fName, i, firstKey)
+	}
+}
+
+// ReadGenSchema reads a gen schema file of the form:
+//
+//	key: id
+//	columns:
+//	  name: string
+//	  amount: float
+//	  status: choice(active|inactive)
+func ReadGenSchema(fName string) GenSchema {
+
+	data, err := os.ReadFile(fName)
+	if err != nil {
+		fatalf("cannot read schema file %s: %v", fName, err)
+	}
+
+	doc, err := ParseYAML(string(data))
+	if err != nil {
+		fatalf("cannot parse schema file %s: %v", fName, err)
+	}
+
+	schema := GenSchema{Key: doc.String("key")}
+	if schema.Key == "" {
+		schema.Key = "id"
+	}
+
+	cols := doc.Map("columns")
+	if cols == nil {
+		fatalf("schema file %s has no columns", fName)
+	}
+
+	for _, name := range cols.Keys {
+		typ := cols.String(name)
+		if !validGenType(typ) {
+			fatalf("schema file %s: column %s has unknown type %q", fName, name, typ)
+		}
+		schema.Columns = append(schema.Columns, GenColumn{Name: name, Type: typ})
+	}
+
+	return schema
+}
+
+// validGenType reports whether typ is a type gen knows how to produce.
+func validGenType(typ string) bool {
+
+	switch typ {
+	case "string", "int", "float", "date", "bool":
+		return true
+	}
+
+	return strings.HasPrefix(typ, "choice(") && strings.HasSuffix(typ, ")")
+}
+
+type generator struct {
+	rnd    *rand.Rand
+	schema GenSchema
+	rows   int
+	dups   float64
+	dirty  float64
+}
+
+// writeFile writes one fixture. Only the key column is named the same in
+// every file, so that the fixtures join on it alone.
+func (g *generator) writeFile(fName string, fileNum int, firstKey int) {
+
+	f, err := os.Create(fName)
+	if err != nil {
+		fatalf("cannot create %s: %v", fName, err)
+	}
+
+	w := csv.NewWriter(f)
+
+	header := []string{g.schema.Key}
+	for _, col := range g.schema.Columns {
+		name := col.Name
+		if fileNum > 1 {
+			name = fmt.Sprintf("%s_%d", col.Name, fileNum)
+		}
+		header = append(header, name)
+	}
+	w.Write(header)
+
+	next := firstKey
+
+	for i := 0; i < g.rows; i++ {
+
+		key := next
+		if i > 0 && g.rnd.Float64() < g.dups {
+			key = firstKey + g.rnd.Intn(next-firstKey)
+		} else {
+			next++
+		}
+
+		row := []string{g.mangle(strconv.Itoa(key))}
+		for _, col := range g.schema.Columns {
+			row = append(row, g.mangle(g.value(col.Type)))
+		}
+		w.Write(row)
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		fatalf("failed writing %s: %v", fName, err)
+	}
+	if err := f.Close(); err != nil {
+		fatalf("failed writing %s: %v", fName, err)
+	}
+}
+
+// value produces a random value of the given type.
+func (g *generator) value(typ string) string {
+
+	switch typ {
+	case "string":
+		return genWords[g.rnd.Intn(len(genWords))]
+	case "int":
+		return strconv.Itoa(g.rnd.Intn(100000))
+	case "float":
+		return strconv.FormatFloat(g.rnd.Float64()*10000, 'f', 2, 64)
+	case "date":
+		base := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+		return base.AddDate(0, 0, g.rnd.Intn(365*5)).Format("2006-01-02")
+	case "bool":
+		return strconv.FormatBool(g.rnd.Intn(2) == 0)
+	}
+
+	choices := strings.Split(strings.TrimSuffix(strings.TrimPrefix(typ, "choice("), ")"), "|")
+	return choices[g.rnd.Intn(len(choices))]
+}
+
+// mangle makes a fraction of values dirty, the way real exports tend to be.
+func (g *generator) mangle(v string) string {
+
+	if g.rnd.Float64() >= g.dirty {
+		return v
+	}
+
+	switch g.rnd.Intn(4) {
+	case 0:
+		return " " + v + " "
+	case 1:
+		return strings.ToUpper(v)
+	case 2:
+		return ""
+	default:
+		return "\"" + v + "\""
+	}
+}
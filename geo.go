@@ -0,0 +1,284 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var geoJoinOpt = flag.String("geo-join", "", "join two inputs on distance instead of keys, as 'a:lat,lon within 500m of b:lat,lon'. distances may be in m, km or mi. adds a distance_m column")
+
+var geoJoinPattern = regexp.MustCompile(`^\s*(.+?):([^,\s]+),([^,\s]+)\s+within\s+([0-9.]+)\s*(m|km|mi)\s+of\s+(.+?):([^,\s]+),([^,\s]+)\s*$`)
+
+// earthRadius is the mean radius of the Earth in metres.
+const earthRadius = 6371008.8
+
+// geoSide is one input of a --geo-join: its position and coordinate columns.
+type geoSide struct {
+	src      int
+	lat, lon string
+}
+
+// geoCell identifies a cell in a geohash grid of a fixed precision, by its
+// row and column.
+type geoCell struct {
+	row, col int
+}
+
+// geoGrid divides the globe into the cells of a geohash of a given precision:
+// 5 bits per character, alternately splitting longitude and latitude.
+type geoGrid struct {
+	latCells, lonCells int
+}
+
+// newGeoGrid picks the finest geohash precision whose cells are at least
+// within metres tall, so that a search rarely spans many cells.
+func newGeoGrid(within float64) geoGrid {
+
+	g := geoGrid{latCells: 4, lonCells: 8}
+
+	for p := 2; p <= 12; p++ {
+		bits := 5 * p
+		next := geoGrid{latCells: 1 << (bits / 2), lonCells: 1 << ((bits + 1) / 2)}
+		if next.cellHeight() < within {
+			break
+		}
+		g = next
+	}
+
+	return g
+}
+
+// cellHeight returns the height of a cell in metres.
+func (g geoGrid) cellHeight() float64 {
+	return math.Pi * earthRadius / float64(g.latCells)
+}
+
+// cell returns the cell holding a point.
+func (g geoGrid) cell(lat, lon float64) geoCell {
+
+	row := int((lat + 90) / 180 * float64(g.latCells))
+	col := int((lon + 180) / 360 * float64(g.lonCells))
+
+	return geoCell{row: min(max(row, 0), g.latCells-1), col: ((col % g.lonCells) + g.lonCells) % g.lonCells}
+}
+
+// around returns every cell holding points that may be within metres of a
+// point, allowing for cells narrowing towards the poles.
+func (g geoGrid) around(lat, lon, within float64) []geoCell {
+
+	dLat := within / earthRadius * 180 / math.Pi
+	lo, hi := g.cell(max(lat-dLat, -90), lon), g.cell(min(lat+dLat, 90), lon)
+
+	// The widest span of longitude is at the latitude nearest a pole.
+	widest := max(math.Abs(lat-dLat), math.Abs(lat+dLat))
+	cols := g.lonCells
+	if cos := math.Cos(widest * math.Pi / 180); widest < 90 && cos > 0 {
+		dLon := dLat / cos
+		if dLon < 180 {
+			cols = 2*int(math.Ceil(dLon/360*float64(g.lonCells))) + 1
+		}
+	}
+
+	center := g.cell(lat, lon).col
+	cells := []geoCell{}
+
+	for row := lo.row; row <= hi.row; row++ {
+		for k := 0; k < min(cols, g.lonCells); k++ {
+			col := center - cols/2 + k
+			if cols >= g.lonCells {
+				col = k
+			}
+			cells = append(cells, geoCell{row: row, col: ((col % g.lonCells) + g.lonCells) % g.lonCells})
+		}
+	}
+
+	return cells
+}
+
+// haversine returns the great circle distance between two points in metres.
+func haversine(lat1, lon1, lat2, lon2 float64) float64 {
+
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLon := (lon2 - lon1) * rad
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// parseGeoJoin reads --geo-join against the inputs.
+func parseGeoJoin(fileNames []string, allHeaders [][]string) (geoSide, geoSide, float64) {
+
+	m := geoJoinPattern.FindStringSubmatch(*geoJoinOpt)
+	if m == nil {
+		usagef("--geo-join %s must look like 'a:lat,lon within 500m of b:lat,lon'", *geoJoinOpt)
+	}
+
+	side := func(file, lat, lon string) geoSide {
+		i := FileIndex(fileNames, file)
+		if i < 0 {
+			usagef("--geo-join names %s, which is not an input file", file)
+		}
+		for _, col := range []string{lat, lon} {
+			if !contains(allHeaders[i], col) {
+				fail(&RunError{Class: errSchema, File: fileNames[i], Message: fmt.Sprintf("--geo-join column %s not found in %s", col, fileNames[i])})
+			}
+		}
+		return geoSide{src: i, lat: lat, lon: lon}
+	}
+
+	left, right := side(m[1], m[2], m[3]), side(m[6], m[7], m[8])
+	if left.src == right.src {
+		usagef("--geo-join needs two different inputs")
+	}
+	if len(fileNames) != 2 {
+		usagef("--geo-join joins exactly two inputs")
+	}
+
+	within, _ := strconv.ParseFloat(m[4], 64)
+	switch m[5] {
+	case "km":
+		within *= 1000
+	case "mi":
+		within *= 1609.344
+	}
+	if within <= 0 {
+		usagef("--geo-join distance must be more than zero")
+	}
+
+	return left, right, within
+}
+
+// pointOf reads a record's coordinates.
+func pointOf(row []string, header []string, side geoSide) (float64, float64, bool) {
+
+	lat, ok1 := parseNumber(row[indexOf(header, side.lat)])
+	lon, ok2 := parseNumber(row[indexOf(header, side.lon)])
+
+	return lat, lon, ok1 && ok2 && lat >= -90 && lat <= 90 && lon >= -180 && lon <= 180
+}
+
+// pairHeader is the output header of a join that pairs rows of two inputs
+// whole: every column of the left, then every column of the right, the
+// right's renamed as file.col where the names clash, then any extra columns.
+func pairHeader(left, right []string, rightName string, extra ...string) []string {
+
+	base := filepath.Base(rightName)
+	base = strings.TrimSuffix(base, filepath.Ext(base))
+
+	header := append([]string{}, left...)
+	for _, col := range right {
+		if contains(left, col) {
+			col = base + "." + col
+		}
+		header = append(header, col)
+	}
+
+	return append(header, extra...)
+}
+
+// GeoJoin joins two inputs by --geo-join, pairing each row of the first
+// named input with every row of the second within the distance. Rows with no
+// partner, including those without readable coordinates, are output on their
+// own as a key join outputs unmatched keys.
+func GeoJoin(readers []RowReader, fileNames []string, w RowWriter) {
+
+	inputNames = fileNames
+
+	loaded := LoadAll(readers, fileNames)
+	allHeaders := [][]string{}
+	for i, rows := range loaded {
+		if len(rows) == 0 {
+			fail(&RunError{Class: errInput, File: fileNames[i], Message: fmt.Sprintf("%s is empty", fileNames[i])})
+		}
+		allHeaders = append(allHeaders, rows[0])
+		recordInputRows(i, len(rows)-1)
+	}
+
+	left, right, within := parseGeoJoin(fileNames, allHeaders)
+	lHeader, rHeader := allHeaders[left.src], allHeaders[right.src]
+	lRows, rRows := loaded[left.src][1:], loaded[right.src][1:]
+
+	grid := newGeoGrid(within)
+	index := map[geoCell][]int{}
+	unplaced := 0
+
+	for i, row := range rRows {
+		lat, lon, ok := pointOf(row, rHeader, right)
+		if !ok {
+			unplaced++
+			continue
+		}
+		c := grid.cell(lat, lon)
+		index[c] = append(index[c], i)
+	}
+
+	writer = w
+	rowsWritten = 0
+
+	err := writer.Write(pairHeader(lHeader, rHeader, fileNames[right.src], "distance_m"))
+	if err != nil {
+		fail(&RunError{Class: errOutput, Message: fmt.Sprintf("failed to write CSV output: %v", err)})
+	}
+
+	blankRight := make([]string, len(rHeader))
+	blankLeft := make([]string, len(lHeader))
+	matched := make([]bool, len(rRows))
+
+	emit := func(l, r []string, distance string) bool {
+		if rowLimit > 0 && rowsWritten >= rowLimit {
+			return false
+		}
+		row := append(append(append(newRow(len(l)+len(r)+1), l...), r...), distance)
+		WriteRow(row)
+		return true
+	}
+
+	for _, lRow := range lRows {
+
+		lat, lon, ok := pointOf(lRow, lHeader, left)
+		if !ok {
+			unplaced++
+			if !emit(lRow, blankRight, "") {
+				return
+			}
+			continue
+		}
+
+		found := false
+		for _, c := range grid.around(lat, lon, within) {
+			for _, i := range index[c] {
+				rLat, rLon, _ := pointOf(rRows[i], rHeader, right)
+				d := haversine(lat, lon, rLat, rLon)
+				if d > within {
+					continue
+				}
+				found, matched[i] = true, true
+				if !emit(lRow, rRows[i], strconv.FormatFloat(d, 'f', 1, 64)) {
+					return
+				}
+			}
+		}
+
+		if !found && !emit(lRow, blankRight, "") {
+			return
+		}
+	}
+
+	for i, rRow := range rRows {
+		if !matched[i] && !emit(blankLeft, rRow, "") {
+			return
+		}
+	}
+
+	if unplaced > 0 {
+		log.Printf("%d rows had no readable coordinates and were output unmatched", unplaced)
+	}
+}
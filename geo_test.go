@@ -0,0 +1,74 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestHaversine(t *testing.T) {
+
+	tests := []struct {
+		lat1, lon1, lat2, lon2 float64
+		want                   float64
+	}{
+		{0, 0, 0, 0, 0},
+		{51.5074, -0.1278, 48.8566, 2.3522, 343500},
+		{0, 179.9, 0, -179.9, 22239},
+		{90, 0, 90, 120, 0},
+		{0, 0, 0, 180, math.Pi * earthRadius},
+	}
+
+	for _, tt := range tests {
+		got := haversine(tt.lat1, tt.lon1, tt.lat2, tt.lon2)
+		if math.Abs(got-tt.want) > max(tt.want*0.001, 1) {
+			t.Errorf("haversine(%v, %v, %v, %v) = %.0f, want %.0f", tt.lat1, tt.lon1, tt.lat2, tt.lon2, got, tt.want)
+		}
+	}
+}
+
+func TestGeoGridAroundFindsNearbyPoints(t *testing.T) {
+
+	rnd := rand.New(rand.NewSource(1))
+
+	for _, within := range []float64{50, 1000, 25000, 500000} {
+		g := newGeoGrid(within)
+		if g.cellHeight() < within {
+			t.Errorf("grid for %.0fm has cells only %.0fm tall", within, g.cellHeight())
+		}
+
+		for i := 0; i < 2000; i++ {
+			// Half the points near a pole or the antimeridian, where cells
+			// wrap and narrow.
+			lat, lon := rnd.Float64()*180-90, rnd.Float64()*360-180
+			switch i % 4 {
+			case 1:
+				lat = 89.9 - rnd.Float64()*0.5
+			case 2:
+				lon = 179.99
+			}
+
+			// A second point up to within away, in a random direction.
+			d := rnd.Float64() * within / earthRadius * 180 / math.Pi
+			bearing := rnd.Float64() * 2 * math.Pi
+			lat2 := lat + d*math.Cos(bearing)
+			lon2 := lon + d*math.Sin(bearing)/math.Max(math.Cos(lat*math.Pi/180), 1e-6)
+			if lat2 > 90 || lat2 < -90 {
+				continue
+			}
+			lon2 = math.Mod(lon2+540, 360) - 180
+			if haversine(lat, lon, lat2, lon2) > within {
+				continue
+			}
+
+			want := g.cell(lat2, lon2)
+			found := false
+			for _, c := range g.around(lat, lon, within) {
+				found = found || c == want
+			}
+			if !found {
+				t.Fatalf("within %.0fm: around(%v, %v) misses cell %v of (%v, %v), %.0fm away", within, lat, lon, want, lat2, lon2, haversine(lat, lon, lat2, lon2))
+			}
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+var (
+	gsheetOpt         = flag.String("gsheet", "", "write the output to a Google Sheet instead, as <spreadsheet-id>!<sheet name>. the sheet is cleared first")
+	gsheetCredentials = flag.String("gsheet-credentials", "", "service account key file for --gsheet. defaults to $GOOGLE_APPLICATION_CREDENTIALS")
+)
+
+// sheetsAPI is the Google Sheets values API.
+var sheetsAPI = "https://sheets.googleapis.com/v4/spreadsheets"
+
+// SheetWriter is a RowWriter that collects the output for a Google Sheet,
+// uploading it all when finished.
+type SheetWriter struct {
+	spreadsheet string
+	sheet       string
+	rows        [][]string
+}
+
+// NewSheetWriter returns the SheetWriter for --gsheet, or nil if it is not
+// set.
+func NewSheetWriter() *SheetWriter {
+
+	if *gsheetOpt == "" {
+		return nil
+	}
+
+	id, sheet, ok := splitPair(*gsheetOpt, "!")
+	if !ok {
+		usagef("--gsheet %s must be <spreadsheet-id>!<sheet name>", *gsheetOpt)
+	}
+
+	return &SheetWriter{spreadsheet: id, sheet: sheet}
+}
+
+// Write holds a row for upload.
+func (s *SheetWriter) Write(row []string) error {
+
+	s.rows = append(s.rows, append([]string{}, row...))
+
+	return nil
+}
+
+// serviceAccount holds the fields needed from a service account key file.
+type serviceAccount struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// Upload clears the sheet and writes the collected rows to it.
+func (s *SheetWriter) Upload() {
+
+	token, err := sheetsToken()
+	if err != nil {
+		fail(&RunError{Class: errOutput, Message: fmt.Sprintf("cannot authorize Google Sheets access: %v", err), Hint: "check the --gsheet-credentials service account key, and that the sheet is shared with the service account"})
+	}
+
+	base := fmt.Sprintf("%s/%s/values/%s", sheetsAPI, url.PathEscape(s.spreadsheet), url.PathEscape(s.sheet))
+
+	err = sheetsCall(token, http.MethodPost, base+":clear", struct{}{})
+	if err == nil {
+		body := struct {
+			Range  string     `json:"range"`
+			Values [][]string `json:"values"`
+		}{s.sheet, s.rows}
+		err = sheetsCall(token, http.MethodPut, base+"?valueInputOption=RAW", body)
+	}
+	if err != nil {
+		fail(&RunError{Class: errOutput, Message: fmt.Sprintf("failed writing to Google Sheet %s: %v", *gsheetOpt, err)})
+	}
+}
+
+// sheetsCall makes a Sheets API request, returning an error for any failure
+// status.
+func sheetsCall(token, method, endpoint string, body any) error {
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(method, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := (&http.Client{Timeout: 5 * time.Minute}).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1000))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	return nil
+}
+
+// sheetsToken exchanges a JWT signed with the service account's key for an
+// access token, following Google's OAuth flow for service accounts.
+func sheetsToken() (string, error) {
+
+	keyFile := *gsheetCredentials
+	if keyFile == "" {
+		keyFile = os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	}
+	if keyFile == "" {
+		return "", fmt.Errorf("no service account key: use --gsheet-credentials or set GOOGLE_APPLICATION_CREDENTIALS")
+	}
+
+	data, err := os.ReadFile(keyFile)
+	if err != nil {
+		return "", err
+	}
+
+	var sa serviceAccount
+	if err := json.Unmarshal(data, &sa); err != nil {
+		return "", fmt.Errorf("%s: %v", keyFile, err)
+	}
+	if sa.TokenURI == "" {
+		sa.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+
+	block, _ := pem.Decode([]byte(sa.PrivateKey))
+	if block == nil {
+		return "", fmt.Errorf("%s: no private key", keyFile)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return "", fmt.Errorf("%s: %v", keyFile, err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return "", fmt.Errorf("%s: private key is not RSA", keyFile)
+	}
+
+	now := time.Now()
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims, _ := json.Marshal(map[string]any{
+		"iss":   sa.ClientEmail,
+		"scope": "https://www.googleapis.com/auth/spreadsheets",
+		"aud":   sa.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	unsigned := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+
+	digest := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	jwt := unsigned + "." + base64.RawURLEncoding.EncodeToString(sig)
+
+	resp, err := (&http.Client{Timeout: time.Minute}).PostForm(sa.TokenURI, url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {jwt},
+	})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var reply struct {
+		AccessToken string `json:"access_token"`
+		Error       string `json:"error"`
+		Description string `json:"error_description"`
+	}
+	json.NewDecoder(resp.Body).Decode(&reply)
+	if resp.StatusCode >= 300 || reply.AccessToken == "" {
+		return "", fmt.Errorf("token request failed: %s %s %s", resp.Status, reply.Error, reply.Description)
+	}
+
+	return reply.AccessToken, nil
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"strconv"
+)
+
+var (
+	hierarchyOpt   = flag.String("hierarchy", "", "flatten a single input's tree, as parent_id=id: each row is output with its depth and the level_1 (root) to level_N columns of its path down from the root")
+	hierarchyDepth = flag.Int("hierarchy-depth", 10, "number of level columns --hierarchy outputs. rows nested deeper keep the top levels")
+	hierarchyLabel = flag.String("hierarchy-label", "", "column whose values fill the --hierarchy level columns. by default, the id column")
+)
+
+// HierarchyJoin implements --hierarchy: the one input is joined to itself
+// repeatedly, each row to its parent, until the root. Rows whose parent is
+// missing are treated as roots, and a loop of parents is an error.
+func HierarchyJoin(readers []RowReader, fileNames []string, w RowWriter) {
+
+	inputNames = fileNames
+
+	if len(fileNames) != 1 {
+		usagef("--hierarchy flattens a single input, but %d were given", len(fileNames))
+	}
+	if *hierarchyDepth < 1 {
+		usagef("--hierarchy-depth must be at least 1")
+	}
+
+	rows := LoadAll(readers, fileNames)[0]
+	if len(rows) == 0 {
+		fail(&RunError{Class: errInput, File: fileNames[0], Message: fmt.Sprintf("%s is empty", fileNames[0])})
+	}
+	header, data := rows[0], rows[1:]
+	recordInputRows(0, len(data))
+
+	parentCol, idCol, ok := splitPair(*hierarchyOpt, "=")
+	if !ok {
+		usagef("--hierarchy %s must be parent_col=id_col", *hierarchyOpt)
+	}
+	labelCol := *hierarchyLabel
+	if labelCol == "" {
+		labelCol = idCol
+	}
+	for _, col := range []string{parentCol, idCol, labelCol} {
+		if !contains(header, col) {
+			fail(&RunError{Class: errSchema, File: fileNames[0], Message: fmt.Sprintf("--hierarchy column %s not found in %s", col, fileNames[0])})
+		}
+	}
+	parent, id, label := indexOf(header, parentCol), indexOf(header, idCol), indexOf(header, labelCol)
+
+	out := append([]string{}, header...)
+	out = append(out, "depth")
+	for i := 1; i <= *hierarchyDepth; i++ {
+		out = append(out, "level_"+strconv.Itoa(i))
+	}
+	for _, col := range out[len(header):] {
+		if contains(header, col) {
+			usagef("--hierarchy would add a %s column, but %s already has one", col, fileNames[0])
+		}
+	}
+
+	byID := map[string]int{}
+	for i, row := range data {
+		if _, dup := byID[row[id]]; dup {
+			fail(&RunError{
+				Class:   errInput,
+				File:    fileNames[0],
+				Record:  i + 1,
+				Message: fmt.Sprintf("--hierarchy: %s has more than one row with %s %q", fileNames[0], idCol, row[id]),
+			})
+		}
+		byID[row[id]] = i
+	}
+
+	writer = w
+	rowsWritten = 0
+
+	if err := writer.Write(out); err != nil {
+		fail(&RunError{Class: errOutput, Message: fmt.Sprintf("failed to write CSV output: %v", err)})
+	}
+
+	orphans, truncated := 0, 0
+
+	for i, row := range data {
+
+		if rowLimit > 0 && rowsWritten >= rowLimit {
+			break
+		}
+
+		// path runs from the row up to its root.
+		path := []int{i}
+		seen := map[int]bool{i: true}
+		for {
+			p := data[path[len(path)-1]][parent]
+			if p == "" {
+				break
+			}
+			next, ok := byID[p]
+			if !ok {
+				orphans++
+				break
+			}
+			if seen[next] {
+				fail(&RunError{
+					Class:   errInput,
+					File:    fileNames[0],
+					Record:  i + 1,
+					Message: fmt.Sprintf("--hierarchy: the parents of %s %q loop back through %q", idCol, row[id], data[next][id]),
+				})
+			}
+			seen[next] = true
+			path = append(path, next)
+		}
+
+		if len(path) > *hierarchyDepth {
+			truncated++
+		}
+
+		result := append(newRow(len(out)), row...)
+		result = append(result, strconv.Itoa(len(path)-1))
+		for level := 0; level < *hierarchyDepth; level++ {
+			v := ""
+			if at := len(path) - 1 - level; at >= 0 {
+				v = data[path[at]][label]
+			}
+			result = append(result, v)
+		}
+		WriteRow(result)
+	}
+
+	if orphans > 0 {
+		log.Printf("--hierarchy: %d rows have a %s not found as any row's %s, and were taken as roots", orphans, parentCol, idCol)
+	}
+	if truncated > 0 {
+		log.Printf("--hierarchy: %d rows are nested deeper than --hierarchy-depth %d; only their top levels are output", truncated, *hierarchyDepth)
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"io"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	delimiterOpts listFlag
+
+	decimalComma = flag.Bool("decimal-comma", false, "read numbers in the inputs with a decimal comma and optional dot thousands separators, as in 1.234,56")
+	encoding     = flag.String("encoding", "utf-8", "character encoding of the inputs: utf-8, or cp1252 (Windows Western European), which is converted to UTF-8")
+	stripBOM     = flag.Bool("strip-bom", false, "remove a UTF-8 byte order mark from the start of each input")
+)
+
+func init() {
+	flag.Var(&delimiterOpts, "delimiter", "field separator of the inputs: a single character, or tab (default ,). give file=char, as data.tsv=tab, for one input's own. may be repeated")
+}
+
+// InputDelimiter returns the --delimiter character for inputs not given one
+// of their own.
+func InputDelimiter() rune {
+
+	comma := ','
+	for _, d := range delimiterOpts {
+		if _, _, perFile := splitDelimiter(d); !perFile {
+			comma = parseDelimiter(d)
+		}
+	}
+
+	return comma
+}
+
+// InputDelimiters returns the --delimiter character of each input.
+func InputDelimiters(fileNames []string) []rune {
+
+	commas := make([]rune, len(fileNames))
+	for i := range commas {
+		commas[i] = InputDelimiter()
+	}
+
+	for _, d := range delimiterOpts {
+		file, value, perFile := splitDelimiter(d)
+		if !perFile {
+			continue
+		}
+		i := FileIndex(fileNames, file)
+		if i < 0 {
+			usagef("--delimiter names %s, which is not an input file", file)
+		}
+		commas[i] = parseDelimiter(value)
+	}
+
+	return commas
+}
+
+// splitDelimiter splits a --delimiter given as file=char. A delimiter on its
+// own is a single character or tab, so anything longer holding = names a
+// file.
+func splitDelimiter(d string) (string, string, bool) {
+
+	if utf8.RuneCountInString(d) <= 1 || d == "tab" || d == `\t` {
+		return "", d, false
+	}
+
+	return splitPair(d, "=")
+}
+
+// parseDelimiter reads a delimiter: a single character, or tab.
+func parseDelimiter(d string) rune {
+
+	if d == "tab" || d == `\t` {
+		return '\t'
+	}
+
+	r, size := utf8.DecodeRuneInString(d)
+	if size == 0 || size != len(d) || r == '"' || r == '\r' || r == '\n' || r == utf8.RuneError {
+		usagef("--delimiter %q must be a single character other than a quote or newline, or file=char", d)
+	}
+
+	return r
+}
+
+// DecodeInput applies --strip-bom and --encoding to an input's bytes.
+func DecodeInput(r io.Reader) io.Reader {
+
+	switch *encoding {
+	case "utf-8", "utf8":
+	case "cp1252", "windows-1252":
+	default:
+		usagef("--encoding %s must be utf-8 or cp1252", *encoding)
+	}
+
+	if *stripBOM {
+		br := bufio.NewReader(r)
+		if bom, err := br.Peek(3); err == nil && string(bom) == "\xef\xbb\xbf" {
+			br.Discard(3)
+		}
+		r = br
+	}
+
+	if *encoding == "cp1252" || *encoding == "windows-1252" {
+		r = &cp1252Reader{r: r}
+	}
+
+	return r
+}
+
+// cp1252High maps the bytes 0x80 to 0x9f of Windows-1252, where it differs
+// from Latin-1. Undefined bytes become U+FFFD.
+var cp1252High = [32]rune{
+	'€', '�', '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', '�', 'Ž', '�',
+	'�', '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', '�', 'ž', 'Ÿ',
+}
+
+// cp1252Reader converts Windows-1252 text to UTF-8. err is what the
+// underlying reader returned along with the bytes held in pending, returned
+// itself once they are read.
+type cp1252Reader struct {
+	r       io.Reader
+	buf     []byte
+	pending []byte
+	err     error
+}
+
+// Read returns converted text.
+func (c *cp1252Reader) Read(p []byte) (int, error) {
+
+	if len(c.pending) == 0 {
+		if c.err != nil {
+			return 0, c.err
+		}
+		if cap(c.buf) < len(p) {
+			c.buf = make([]byte, len(p))
+		}
+		n, err := c.r.Read(c.buf[:len(p)])
+		c.err = err
+		if n == 0 {
+			return 0, err
+		}
+		out := c.pending[:0]
+		for _, b := range c.buf[:n] {
+			switch {
+			case b < 0x80:
+				out = append(out, b)
+			case b < 0xa0:
+				out = utf8.AppendRune(out, cp1252High[b-0x80])
+			default:
+				out = utf8.AppendRune(out, rune(b))
+			}
+		}
+		c.pending = out
+	}
+
+	n := copy(p, c.pending)
+	c.pending = c.pending[n:]
+
+	return n, nil
+}
+
+var thousandsPattern = regexp.MustCompile(`^[+-]?\d{1,3}(\.\d{3})+$`)
+
+// numberText rewrites a number written with --decimal-comma in the usual
+// form, so 1.234,56 reads as 1234.56. Other values are returned unchanged.
+func numberText(s string) string {
+
+	if !*decimalComma {
+		return s
+	}
+
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, ",") || thousandsPattern.MatchString(s) {
+		s = strings.ReplaceAll(s, ".", "")
+		s = strings.Replace(s, ",", ".", 1)
+	}
+
+	return s
+}
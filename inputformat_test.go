@@ -0,0 +1,52 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestCP1252Reader(t *testing.T) {
+
+	got, err := io.ReadAll(&cp1252Reader{r: iotest.HalfReader(strings.NewReader("caf\xe9 \x80 \x93q\x94"))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "café € “q”"; string(got) != want {
+		t.Errorf("read %q, want %q", got, want)
+	}
+}
+
+func TestCP1252ReaderKeepsError(t *testing.T) {
+
+	broken := errors.New("disk on fire")
+	r := &onceErrReader{data: "ab", err: broken}
+
+	got, err := io.ReadAll(&cp1252Reader{r: r})
+	if !errors.Is(err, broken) {
+		t.Errorf("err = %v, want %v", err, broken)
+	}
+	if string(got) != "ab" {
+		t.Errorf("read %q before the error, want \"ab\"", got)
+	}
+}
+
+// onceErrReader returns its data together with err, then reads as at the end.
+type onceErrReader struct {
+	data string
+	err  error
+	done bool
+}
+
+// Read returns the data and error the first time, then io.EOF.
+func (r *onceErrReader) Read(p []byte) (int, error) {
+
+	if r.done {
+		return 0, io.EOF
+	}
+	r.done = true
+
+	return copy(p, r.data), r.err
+}
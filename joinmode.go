@@ -0,0 +1,91 @@
+package main
+
+import (
+	"flag"
+)
+
+var joinMode = flag.String("join", "full", "which keys are output: full (keys from any input), left (only keys in the first input), inner (only keys in every input), anti (the first input's rows with keys in no other input) or semi (the first input's rows with keys in every other input)")
+
+// checkJoinMode fails if --join is not a known mode.
+func checkJoinMode() {
+
+	switch *joinMode {
+	case "full", "left", "inner", "anti", "semi":
+	default:
+		usagef("--join %s must be full, left, inner, anti or semi", *joinMode)
+	}
+
+	if *asOfCol != "" && *joinMode != "full" && *joinMode != "left" {
+		usagef("--as-of only outputs the first input's rows, and cannot be used with --join %s", *joinMode)
+	}
+}
+
+// keepKey reports whether a key is output under --join, given whether the
+// first input has it and how many of the inputs do.
+func keepKey(first bool, in int, inputs int) bool {
+
+	if *asOfCol != "" {
+		return first
+	}
+
+	switch *joinMode {
+	case "left":
+		return first
+	case "inner", "semi":
+		return in == inputs
+	case "anti":
+		return first && in == 1
+	}
+
+	return true
+}
+
+// joinDropReason describes why --join left a key's rows out.
+func joinDropReason() string {
+
+	if *asOfCol != "" {
+		return "key not in the first input (--as-of)"
+	}
+
+	switch *joinMode {
+	case "inner", "semi":
+		return "key not in every input (--join " + *joinMode + ")"
+	case "anti":
+		return "not an unmatched row of the first input (--join anti)"
+	}
+
+	return "key not in the first input (--join " + *joinMode + ")"
+}
+
+// JoinOutputColumns returns the output columns for --join: the first input's
+// columns for an anti or semi join, which only output that input's rows,
+// otherwise outputColumns.
+func JoinOutputColumns(allHeaders [][]string, outputColumns []string) []string {
+
+	if *joinMode == "anti" || *joinMode == "semi" {
+		return allHeaders[0]
+	}
+
+	return outputColumns
+}
+
+// semiRows prints each of the first input's records for key once. A semi join
+// only needs to know the other inputs have the key, so it does not go through
+// their records.
+func semiRows(key string, allData []DataCollection, prt Printer) {
+
+	first := 0
+	for k, orig := range sourceOrder {
+		if orig == 0 {
+			first = k
+		}
+	}
+
+	for _, rec := range allData[first].data[key] {
+		recs := make([]Record, len(allData))
+		recs[first] = rec
+		if !prt(recs) {
+			return
+		}
+	}
+}
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	keyNormalize   = flag.String("key-normalize", "", "comma separated normalizations applied to join key values: scientific, lower (ignore case), trim (ignore surrounding spaces) or both (lower and trim)")
+	keySep         = flag.String("key-sep", "++", "separator between the column values of a composite join key, as seen in --emit-key and --audit-keys. separator characters and \\ in values are escaped with \\ so distinct keys never collide")
+	keyType        = flag.String("key-type", "string", "how join key values are matched: string, or numeric (parsed as numbers, so 1, 1.0 and 01 match)")
+	repairExcelIDs = flag.Bool("repair-excel-ids", false, "restore join key values mangled into floats by spreadsheets (1.00000000000001E+18, 123.0) to integers, reporting each repair")
+
+	// scientificKeys counts the key values that were expanded from scientific
+	// notation.
+	scientificKeys int
+)
+
+var (
+	keyTransformOpts listFlag
+	keyDateOpts      listFlag
+	keyPadOpts       listFlag
+)
+
+func init() {
+	flag.Var(&keyDateOpts, "key-date", "read a join column as a date, matching it as RFC 3339, as col or col,layouts=2006-01-02|01/02/2006|Jan 2 2006 (Go time layouts). without layouts the usual date formats are tried. may be repeated")
+	flag.Var(&keyPadOpts, "key-pad", "pad a join column's values to a fixed width for matching only, as col=width[:left|right[:char]], such as id=10:left:0 (the default side and character). empty and longer values are left alone. may be repeated")
+	flag.Var(&keyTransformOpts, "key-transform", "sed style substitution applied to a join column's values for matching only, as 'name:s/ inc\\.?$//i'. output keeps the original values. may be repeated")
+}
+
+var scientificPattern = regexp.MustCompile(`^[+-]?(\d+\.?\d*|\.\d+)[eE][+-]?\d+$`)
+
+// KeyNormalizer returns a function applying the --key-normalize options to a
+// single join column value.
+func KeyNormalizer() func(string) string {
+
+	switch *keyType {
+	case "string", "numeric":
+	default:
+		usagef("--key-type %s must be string or numeric", *keyType)
+	}
+	if *keySep == "" || strings.ContainsAny(*keySep, "\\\x00") {
+		usagef("--key-sep must be set, and may not contain \\ or a NUL")
+	}
+
+	steps := keyUnicodeSteps()
+
+	for _, name := range strings.Split(*keyNormalize, ",") {
+		switch strings.TrimSpace(name) {
+		case "":
+		case "scientific":
+			steps = append(steps, expandScientific)
+		case "lower":
+			steps = append(steps, strings.ToLower)
+		case "trim":
+			steps = append(steps, strings.TrimSpace)
+		case "both":
+			steps = append(steps, strings.TrimSpace, strings.ToLower)
+		default:
+			usagef("unknown --key-normalize option %s", name)
+		}
+	}
+
+	if fold := keyFolder(); fold != nil {
+		steps = append(steps, fold)
+	}
+
+	return func(v string) string {
+		for _, step := range steps {
+			v = step(v)
+		}
+		return v
+	}
+}
+
+// JoinKey builds a composite key from the values of its columns.
+func JoinKey(values []string) string {
+
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = keyPart(v)
+	}
+
+	return strings.Join(parts, *keySep)
+}
+
+// keyPart escapes a key value for joining with --key-sep: a backslash is put
+// before each backslash, each character of the separator and each NUL, so
+// that an unescaped separator character only ever comes from a separator and
+// no two different lists of values make the same key. Nor can a key start
+// with a bare NUL, which is left for separateKeyPrefix.
+func keyPart(v string) string {
+
+	if !strings.ContainsAny(v, *keySep+"\\\x00") {
+		return v
+	}
+
+	sb := strings.Builder{}
+	for _, r := range v {
+		if r == '\\' || r == 0 || strings.ContainsRune(*keySep, r) {
+			sb.WriteByte('\\')
+		}
+		sb.WriteRune(r)
+	}
+
+	return sb.String()
+}
+
+// KeyTransformer returns a function applying the --key-transform
+// substitutions for a join column to one of its values.
+func KeyTransformer() func(col, v string) string {
+
+	subs := map[string][]*Substitution{}
+
+	for _, spec := range keyTransformOpts {
+		col, expr, ok := splitPair(spec, ":")
+		if !ok {
+			usagef("--key-transform %s must be col:s/old/new/flags", spec)
+		}
+		s, err := ParseSubstitution(expr)
+		if err != nil {
+			usagef("--key-transform for %s: %v", col, err)
+		}
+		subs[col] = append(subs[col], s)
+	}
+
+	return func(col, v string) string {
+		for _, s := range subs[col] {
+			v = s.Apply(v)
+		}
+		return v
+	}
+}
+
+// keyPad is a --key-pad setting: the width to pad a column's values to, on
+// which side, and with what.
+type keyPad struct {
+	width int
+	left  bool
+	char  string
+}
+
+// KeyPads parses --key-pad, returning the padding for each column.
+func KeyPads() map[string]keyPad {
+
+	pads := map[string]keyPad{}
+
+	for _, spec := range keyPadOpts {
+		col, rest, ok := splitPair(spec, "=")
+		parts := strings.Split(rest, ":")
+		if !ok || len(parts) > 3 {
+			usagef("--key-pad %s must be col=width[:left|right[:char]]", spec)
+		}
+		width, err := strconv.Atoi(parts[0])
+		if err != nil || width <= 0 {
+			usagef("--key-pad %s needs a positive width", spec)
+		}
+		p := keyPad{width: width, left: true, char: "0"}
+		if len(parts) > 1 {
+			switch parts[1] {
+			case "left":
+			case "right":
+				p.left = false
+			default:
+				usagef("--key-pad %s side must be left or right", spec)
+			}
+		}
+		if len(parts) > 2 {
+			if utf8.RuneCountInString(parts[2]) != 1 {
+				usagef("--key-pad %s must pad with a single character", spec)
+			}
+			p.char = parts[2]
+		}
+		pads[col] = p
+	}
+
+	return pads
+}
+
+// apply pads v to the width. Empty values stay empty, so that they still
+// count as missing keys.
+func (p keyPad) apply(v string) string {
+
+	n := utf8.RuneCountInString(v)
+	if v == "" || n >= p.width {
+		return v
+	}
+
+	fill := strings.Repeat(p.char, p.width-n)
+	if p.left {
+		return fill + v
+	}
+
+	return v + fill
+}
+
+// KeyDates parses --key-date, returning the layouts to read each date
+// column with.
+func KeyDates() map[string][]string {
+
+	dates := map[string][]string{}
+
+	for _, spec := range keyDateOpts {
+		col, layouts, hasLayouts := strings.Cut(spec, ",")
+		if col == "" {
+			usagef("--key-date %s must be col or col,layouts=...", spec)
+		}
+		if !hasLayouts {
+			dates[col] = timeLayouts
+			continue
+		}
+		list, ok := strings.CutPrefix(layouts, "layouts=")
+		if !ok || list == "" {
+			usagef("--key-date %s must be col or col,layouts=...", spec)
+		}
+		dates[col] = strings.Split(list, "|")
+	}
+
+	return dates
+}
+
+// dateKey reads a date with the first of layouts that fits, returning it in
+// RFC 3339 form in UTC. Empty values stay empty.
+func dateKey(v string, layouts []string) (string, bool) {
+
+	s := strings.TrimSpace(v)
+	if s == "" {
+		return "", true
+	}
+
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC().Format(time.RFC3339), true
+		}
+	}
+
+	return v, false
+}
+
+// RepairKeys applies --repair-excel-ids to the join columns of a record,
+// logging each value changed.
+func RepairKeys(rec Record, joinColumns []string, file string, recNum int) {
+
+	if !*repairExcelIDs {
+		return
+	}
+
+	for _, col := range joinColumns {
+		fixed, ok := repairExcelID(rec[col])
+		if !ok || fixed == rec[col] {
+			continue
+		}
+		note := ""
+		if len(strings.TrimLeft(fixed, "+-")) > excelPrecision {
+			note = fmt.Sprintf(" (digits past the %dth may have been lost)", excelPrecision)
+		}
+		log.Printf("%s record %d: repaired %s %q to %s%s", file, recNum, col, rec[col], fixed, note)
+		rec[col] = fixed
+	}
+}
+
+// expandScientific rewrites a value in scientific notation (1.23E+11) as a
+// plain decimal (123000000000). Other values are returned unchanged.
+func expandScientific(v string) string {
+
+	s := strings.TrimSpace(v)
+	if !scientificPattern.MatchString(s) {
+		return v
+	}
+
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return v
+	}
+
+	scientificKeys++
+
+	if r.IsInt() {
+		return r.Num().String()
+	}
+
+	// The number of decimal places needed is the mantissa's fraction digits
+	// less the exponent.
+	e := strings.IndexAny(s, "eE")
+	exp, _ := strconv.Atoi(s[e+1:])
+	places := 0
+	if dot := strings.IndexByte(s[:e], '.'); dot >= 0 {
+		places = e - dot - 1
+	}
+	places -= exp
+
+	return r.FloatString(places)
+}
+
+// numericKey returns the canonical form of a number for --key-type numeric:
+// no leading or trailing zeros, no exponent, and no sign on zero. Empty
+// values stay empty. Returns ok false if the value is not a number.
+func numericKey(v string) (string, bool) {
+
+	s := strings.TrimSpace(numberText(v))
+	if s == "" {
+		return "", true
+	}
+
+	r, ok := new(big.Rat).SetString(s)
+	if !ok || strings.ContainsAny(s, "/xXpP_") {
+		return v, false
+	}
+
+	if r.IsInt() {
+		return r.Num().String(), true
+	}
+
+	// Enough places for every digit given, as in expandScientific.
+	mantissa, exp := s, 0
+	if e := strings.IndexAny(s, "eE"); e >= 0 {
+		mantissa = s[:e]
+		exp, _ = strconv.Atoi(s[e+1:])
+	}
+	places := 0
+	if dot := strings.IndexByte(mantissa, '.'); dot >= 0 {
+		places = len(mantissa) - dot - 1
+	}
+	places -= exp
+
+	return strings.TrimRight(r.FloatString(places), "0"), true
+}
+
+// repairExcelID restores an ID that has been through a spreadsheet as a float,
+// such as 1.00000000000001E+18 or 12345.0, to its integer form. Returns ok
+// false if the value doesn't look like a mangled integer.
+func repairExcelID(v string) (string, bool) {
+
+	s := strings.TrimSpace(v)
+
+	if !scientificPattern.MatchString(s) && !zeroFractionPattern.MatchString(s) {
+		return v, false
+	}
+
+	r, ok := new(big.Rat).SetString(s)
+	if !ok || !r.IsInt() {
+		return v, false
+	}
+
+	return r.Num().String(), true
+}
+
+var zeroFractionPattern = regexp.MustCompile(`^[+-]?\d+\.0+$`)
+
+// excelPrecision is the number of significant digits a spreadsheet keeps.
+// Repaired IDs longer than this may not match the original exactly.
+const excelPrecision = 15
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRealKeysNeverLookSeparated(t *testing.T) {
+
+	defer func(old string) { *keySep = old }(*keySep)
+
+	for _, sep := range []string{"++", "e", "|", "\t"} {
+		*keySep = sep
+		for _, values := range [][]string{{"e"}, {"", "e"}, {"\x00"}, {"", "\x00"}, {`\e`}, {"\x00", "x"}} {
+			if key := JoinKey(values); strings.HasPrefix(key, separateKeyPrefix) {
+				t.Errorf("--key-sep %q: key %q of %q starts like a separated row's", sep, key, values)
+			}
+		}
+	}
+}
+
+func TestExpandScientific(t *testing.T) {
+
+	tests := []struct {
+		in, want string
+	}{
+		{"1.23E+11", "123000000000"},
+		{"1.23e11", "123000000000"},
+		{"4.5E-3", "0.0045"},
+		{"1.2345678901234E+18", "1234567890123400000"},
+		{"-1E+2", "-100"},
+		{" 2E3 ", "2000"},
+		{"12345", "12345"},
+		{"E12", "E12"},
+		{"1.5", "1.5"},
+		{"abc", "abc"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := expandScientific(tt.in); got != tt.want {
+			t.Errorf("expandScientific(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNumericKey(t *testing.T) {
+
+	tests := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"42", "42", true},
+		{"042", "42", true},
+		{"42.0", "42", true},
+		{"42.50", "42.5", true},
+		{"+7", "7", true},
+		{"-0", "0", true},
+		{"-0.0", "0", true},
+		{"0.000", "0", true},
+		{" 12 ", "12", true},
+		{"1e3", "1000", true},
+		{"1.5E+2", "150", true},
+		{"1.23E-3", "0.00123", true},
+		{"-2.50e-1", "-0.25", true},
+		{".5", "0.5", true},
+		{"5.", "5", true},
+		{"123456789012345678901234567890", "123456789012345678901234567890", true},
+		{"0.1000000000000000000001", "0.1000000000000000000001", true},
+		{"", "", true},
+		{"   ", "", true},
+		{"abc", "abc", false},
+		{"1/2", "1/2", false},
+		{"0x1F", "0x1F", false},
+		{"1_000", "1_000", false},
+		{"1e", "1e", false},
+		{"--1", "--1", false},
+		{"Inf", "Inf", false},
+		{"NaN", "NaN", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := numericKey(tt.in)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("numericKey(%q) = %q, %v, want %q, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestNumericKeyDecimalComma(t *testing.T) {
+
+	defer func(old bool) { *decimalComma = old }(*decimalComma)
+	*decimalComma = true
+
+	for in, want := range map[string]string{"1.234,50": "1234.5", "0,5": "0.5", "1.234": "1234", "12": "12"} {
+		if got, ok := numericKey(in); got != want || !ok {
+			t.Errorf("with --decimal-comma, numericKey(%q) = %q, %v, want %q", in, got, ok, want)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"flag"
+	"strings"
+	"unicode"
+)
+
+var keyUnicode = flag.String("key-unicode", "", "comma separated Unicode normalizations applied to join key values before any others: nfc (compose letters and combining accents, so the two encodings of é match) or strip-accents (match José with Jose)")
+
+// decompositions is compositions the other way round: each precomposed
+// letter's base letter and mark.
+var decompositions = map[rune][2]rune{}
+
+func init() {
+	for pair, r := range compositions {
+		decompositions[r] = pair
+	}
+}
+
+// keyUnicodeSteps returns the --key-unicode normalizations, in the order
+// given.
+func keyUnicodeSteps() []func(string) string {
+
+	steps := []func(string) string{}
+
+	for _, name := range strings.Split(*keyUnicode, ",") {
+		switch strings.TrimSpace(name) {
+		case "":
+		case "nfc":
+			steps = append(steps, composeNFC)
+		case "strip-accents":
+			steps = append(steps, stripKeyAccents)
+		default:
+			usagef("unknown --key-unicode option %s. use nfc or strip-accents", name)
+		}
+	}
+
+	return steps
+}
+
+// composeNFC joins each Latin letter and the combining marks after it into
+// the precomposed character, where there is one, as Unicode normalization
+// form C does. The letters are first taken apart and their marks put in
+// canonical order, so that marks given in any order compose alike; a mark
+// then composes with its letter unless a mark of the same or a higher class
+// is left between them.
+func composeNFC(s string) string {
+
+	if !hasCombiningMark(s) {
+		return s
+	}
+
+	rs := []rune{}
+	for _, r := range s {
+		rs = appendDecomposed(rs, r)
+	}
+	orderMarks(rs)
+
+	out := []rune{}
+	starter, blocking := -1, 0
+	for _, r := range rs {
+		class := markClass(r)
+		if class == 0 {
+			starter, blocking = len(out), 0
+			out = append(out, r)
+			continue
+		}
+		if starter >= 0 && blocking < class {
+			if c, ok := compositions[[2]rune{out[starter], r}]; ok {
+				out[starter] = c
+				continue
+			}
+		}
+		blocking = class
+		out = append(out, r)
+	}
+
+	return string(out)
+}
+
+// appendDecomposed appends r to rs, taken apart into its base letter and
+// marks if compositions makes it.
+func appendDecomposed(rs []rune, r rune) []rune {
+
+	pair, ok := decompositions[r]
+	if !ok {
+		return append(rs, r)
+	}
+
+	return append(appendDecomposed(rs, pair[0]), pair[1])
+}
+
+// orderMarks sorts each run of marks by combining class, keeping marks of
+// the same class in the order given. A mark of unknown class ends a run.
+func orderMarks(rs []rune) {
+
+	for i := 1; i < len(rs); i++ {
+		for j := i; j > 0; j-- {
+			a, b := combiningClass[rs[j-1]], combiningClass[rs[j]]
+			if a == 0 || b == 0 || a <= b {
+				break
+			}
+			rs[j-1], rs[j] = rs[j], rs[j-1]
+		}
+	}
+}
+
+// markClass returns the combining class of r: 0 if it is not a mark, and
+// past any known class for marks compositions doesn't use, which compose
+// with nothing and keep the marks after them from composing.
+func markClass(r rune) int {
+
+	if class, ok := combiningClass[r]; ok {
+		return class
+	}
+	if unicode.Is(unicode.Mn, r) {
+		return 255
+	}
+
+	return 0
+}
+
+// stripKeyAccents removes accents and other marks from letters, whether
+// precomposed or combining, and spells out letters such as ø and ß that have
+// no accent to remove the way collation does.
+func stripKeyAccents(s string) string {
+
+	sb := strings.Builder{}
+
+	for _, r := range s {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		if base, ok := latinBase[r]; ok {
+			sb.WriteString(base)
+			continue
+		}
+		for {
+			pair, ok := decompositions[r]
+			if !ok {
+				break
+			}
+			r = pair[0]
+		}
+		sb.WriteRune(r)
+	}
+
+	return sb.String()
+}
+
+// hasCombiningMark reports whether s holds a combining mark.
+func hasCombiningMark(s string) bool {
+
+	for _, r := range s {
+		if unicode.Is(unicode.Mn, r) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,61 @@
+package main
+
+import "testing"
+
+func TestComposeNFC(t *testing.T) {
+
+	tests := []struct {
+		in, want string
+	}{
+		{"e\u0301", "\u00e9"},
+		{"Jos\u00e9", "Jos\u00e9"},
+		{"Jose\u0301", "Jos\u00e9"},
+		{"a\u0323\u0302", "\u1ead"},
+		{"a\u0302\u0323", "\u1ead"},
+		{"\u1ea1\u0302", "\u1ead"},
+		{"\u00e2\u0323", "\u1ead"},
+		{"a\u0301\u0302", "\u00e1\u0302"},
+		{"o\u031b\u0323", "\u1ee3"},
+		{"o\u0323\u031b", "\u1ee3"},
+		{"u\u0308\u0301", "\u01d8"},
+		{"a\u0301\u0301", "\u00e1\u0301"},
+		{"c\u0327\u0301", "\u1e09"},
+		{"c\u0301\u0327", "\u1e09"},
+		{"\u0301a", "\u0301a"},
+		{"a\u20dd\u0301", "a\u20dd\u0301"},
+		{"q\u0301", "q\u0301"},
+		{"a\u0328\u0301", "\u0105\u0301"},
+		{"e\u0323\u0302x", "\u1ec7x"},
+		{"plain", "plain"},
+	}
+
+	for _, tt := range tests {
+		if got := composeNFC(tt.in); got != tt.want {
+			t.Errorf("composeNFC(%+q) = %+q, want %+q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestStripKeyAccents(t *testing.T) {
+
+	tests := []struct {
+		in, want string
+	}{
+		{"Jos\u00e9", "Jose"},
+		{"Jose\u0301", "Jose"},
+		{"\u1ead", "a"},
+		{"M\u00fcller", "Muller"},
+		{"\u00d8resund", "Oresund"},
+		{"Stra\u00dfe", "Strasse"},
+		{"\u0141\u00f3d\u017a", "Lodz"},
+		{"na\u00efve caf\u00e9", "naive cafe"},
+		{"plain", "plain"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := stripKeyAccents(tt.in); got != tt.want {
+			t.Errorf("stripKeyAccents(%+q) = %+q, want %+q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	maxFieldSize = flag.String("max-field-size", "", "fail if any field is larger than this (e.g. 64KB, 10MB)")
+	maxLineSize  = flag.String("max-line-size", "", "fail if any record is larger than this (e.g. 1MB)")
+)
+
+// sizeError reports a field or record over the configured limit.
+type sizeError struct {
+	what   string
+	limit  int64
+	line   int
+	offset int64
+}
+
+func (e *sizeError) Error() string {
+	return fmt.Sprintf("%s starting on line %d (byte offset %d) is larger than %d bytes", e.what, e.line, e.offset, e.limit)
+}
+
+// limitReader watches the raw CSV bytes and fails as soon as a field or
+// record grows past its limit, before csv.Reader has buffered all of it. It
+// tracks quoting so that delimiters and newlines inside quoted fields don't
+// count as boundaries. The delimiter may take several bytes; matched counts
+// those of it seen so far, which may end one Read and start the next.
+type limitReader struct {
+	r        io.Reader
+	comma    []byte
+	maxField int64
+	maxLine  int64
+
+	inQuote     bool
+	matched     int
+	offset      int64
+	line        int
+	field       int64
+	fieldStart  int64
+	fieldLine   int
+	record      int64
+	recordStart int64
+	recordLine  int
+}
+
+// LimitReader wraps r with the --max-field-size and --max-line-size checks,
+// or returns r unchanged if neither is set.
+func LimitReader(r io.Reader, comma rune) io.Reader {
+
+	maxField := parseByteSize("--max-field-size", *maxFieldSize)
+	maxLine := parseByteSize("--max-line-size", *maxLineSize)
+
+	if maxField == 0 && maxLine == 0 {
+		return r
+	}
+
+	return &limitReader{
+		r:          r,
+		comma:      utf8.AppendRune(nil, comma),
+		maxField:   maxField,
+		maxLine:    maxLine,
+		line:       1,
+		fieldLine:  1,
+		recordLine: 1,
+	}
+}
+
+// Read passes data through, failing if a limit is exceeded.
+func (l *limitReader) Read(p []byte) (int, error) {
+
+	n, err := l.r.Read(p)
+
+	for i := 0; i < n; i++ {
+
+		c := p[i]
+		l.field++
+		l.record++
+
+		if l.maxField > 0 && l.field > l.maxField {
+			return i, &sizeError{what: "field", limit: l.maxField, line: l.fieldLine, offset: l.fieldStart}
+		}
+		if l.maxLine > 0 && l.record > l.maxLine {
+			return i, &sizeError{what: "record", limit: l.maxLine, line: l.recordLine, offset: l.recordStart}
+		}
+
+		l.offset++
+
+		switch {
+		case c == '"':
+			l.inQuote = !l.inQuote
+			l.matched = 0
+		case c == '\n':
+			l.line++
+			l.matched = 0
+			if !l.inQuote {
+				l.field, l.fieldStart, l.fieldLine = 0, l.offset, l.line
+				l.record, l.recordStart, l.recordLine = 0, l.offset, l.line
+			}
+		case l.inQuote:
+		case c == l.comma[l.matched]:
+			l.matched++
+			if l.matched == len(l.comma) {
+				l.matched = 0
+				l.field, l.fieldStart, l.fieldLine = 0, l.offset, l.line
+			}
+		default:
+			// UTF-8 never starts a character inside another, so a failed
+			// match can only restart here at the delimiter's first byte.
+			l.matched = 0
+			if c == l.comma[0] {
+				l.matched = 1
+			}
+		}
+	}
+
+	return n, err
+}
+
+// parseByteSize reads sizes such as 512, 64KB, 10MB or 1GiB. An empty value
+// is 0, meaning no limit.
+func parseByteSize(option, s string) int64 {
+
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0
+	}
+
+	units := []struct {
+		suffix string
+		scale  int64
+	}{
+		{"KIB", 1 << 10}, {"MIB", 1 << 20}, {"GIB", 1 << 30}, {"TIB", 1 << 40},
+		{"KB", 1000}, {"MB", 1000 * 1000}, {"GB", 1000 * 1000 * 1000}, {"TB", 1000 * 1000 * 1000 * 1000},
+		{"K", 1 << 10}, {"M", 1 << 20}, {"G", 1 << 30}, {"T", 1 << 40},
+		{"B", 1},
+	}
+
+	num := strings.ToUpper(s)
+	scale := int64(1)
+	for _, u := range units {
+		if strings.HasSuffix(num, u.suffix) {
+			num = strings.TrimSpace(strings.TrimSuffix(num, u.suffix))
+			scale = u.scale
+			break
+		}
+	}
+
+	f, err := strconv.ParseFloat(num, 64)
+	if err != nil || f < 0 {
+		usagef("%s %s is not a size such as 64KB or 10MB", option, s)
+	}
+
+	return int64(f * float64(scale))
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestLimitReaderMultibyteDelimiter(t *testing.T) {
+
+	defer func(old string) { *maxFieldSize = old }(*maxFieldSize)
+	*maxFieldSize = "8"
+
+	tests := []struct {
+		input string
+		ok    bool
+	}{
+		{"a¦bbbbbb¦c\n", true},
+		{"a¦bbbbbbbbb¦c\n", false},
+		// æ ends in the same byte as ¦, but doesn't end a field.
+		{"a¦ææææææ¦c\n", false},
+		{"\"x¦y¦z¦w\"¦c\n", false},
+	}
+
+	for _, tt := range tests {
+		_, err := io.ReadAll(LimitReader(iotest.OneByteReader(strings.NewReader(tt.input)), '¦'))
+		if (err == nil) != tt.ok {
+			t.Errorf("%q: err = %v, want ok %v", tt.input, err, tt.ok)
+		}
+	}
+}
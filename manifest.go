@@ -0,0 +1,231 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var (
+	outputFile   = flag.String("output", "", "write the joined output to this file instead of stdout")
+	manifestFile = flag.String("manifest", "", "when finished, write a JSON manifest listing each output file with its row count, size and SHA-256")
+
+	// outputs are the files written this run, for the manifest.
+	outputs []*OutputFile
+)
+
+// OutputFile is an output destination that keeps track of the bytes and rows
+// written to it, and their checksum.
+type OutputFile struct {
+	Name  string
+	Rows  int
+	Bytes int64
+
+	w    io.Writer
+	c    io.Closer
+	hash hash.Hash
+
+	// z, when set, compresses what is written before it reaches w.
+	z io.WriteCloser
+
+	// tmp, when set, is the file actually being written, renamed to Name
+	// once complete.
+	tmp string
+}
+
+// CreateOutput creates the named output file, or uses stdout if name is ""
+// or "-". A regular file is written under a temporary name and renamed into
+// place when closed, so that readers never see it half written.
+func CreateOutput(name string) *OutputFile {
+
+	o := &OutputFile{Name: name, hash: sha256.New()}
+
+	if name == "" || name == "-" {
+		o.Name = "-"
+		o.w = os.Stdout
+		outputs = append(outputs, o)
+		return o
+	}
+
+	checkCompression()
+	mode := OutputMode()
+
+	path := name
+	if info, err := os.Stat(name); err != nil || info.Mode().IsRegular() {
+		o.tmp = fmt.Sprintf("%s.%d.tmp", name, os.Getpid())
+		path = o.tmp
+	}
+
+	// The mode is filtered by the umask, as for any new file.
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		fail(&RunError{Class: errIO, File: name, Message: fmt.Sprintf("cannot create output file %s: %v", name, err)})
+	}
+	o.w, o.c = f, f
+
+	outputs = append(outputs, o)
+	o.z = Compress(name, fileWriter{o})
+
+	if err := ChownOutput(path); err != nil {
+		fail(&RunError{Class: errIO, File: name, Message: fmt.Sprintf("cannot set owner of output file %s: %v", name, err)})
+	}
+
+	return o
+}
+
+// Write writes to the file, through the compressor if there is one.
+func (o *OutputFile) Write(p []byte) (int, error) {
+
+	if o.z != nil {
+		return o.z.Write(p)
+	}
+
+	return o.write(p)
+}
+
+// write writes to the file itself, counting and hashing the bytes, so that
+// the manifest describes the file as stored.
+func (o *OutputFile) write(p []byte) (int, error) {
+
+	n, err := o.w.Write(p)
+	o.Bytes += int64(n)
+	o.hash.Write(p[:n])
+
+	return n, err
+}
+
+// fileWriter writes past an OutputFile's compressor.
+type fileWriter struct {
+	o *OutputFile
+}
+
+// Write writes to the file.
+func (f fileWriter) Write(p []byte) (int, error) {
+	return f.o.write(p)
+}
+
+// Close finishes the file, ending any compressed stream and syncing it to
+// disk first with --fsync, and renames it into place. Stdout is left open.
+func (o *OutputFile) Close() error {
+
+	if o.c == nil {
+		return nil
+	}
+
+	var err error
+	if o.z != nil {
+		err = o.z.Close()
+		o.z = nil
+	}
+	if f, ok := o.c.(*os.File); ok && *fsyncOutput && err == nil {
+		err = f.Sync()
+	}
+	if cerr := o.c.Close(); err == nil {
+		err = cerr
+	}
+	o.c = nil
+
+	if o.tmp == "" {
+		return err
+	}
+	if err == nil {
+		err = os.Rename(o.tmp, o.Name)
+	}
+	if err != nil {
+		os.Remove(o.tmp)
+		return err
+	}
+	o.tmp = ""
+
+	if *fsyncOutput {
+		syncDir(filepath.Dir(o.Name))
+	}
+
+	return nil
+}
+
+// AbandonOutputs removes the temporary files of outputs not yet finished,
+// when a run fails, leaving any earlier version of each output untouched.
+func AbandonOutputs() {
+
+	for _, o := range outputs {
+		if o.tmp == "" {
+			continue
+		}
+		if o.z != nil {
+			o.z.Close()
+			o.z = nil
+		}
+		if o.c != nil {
+			o.c.Close()
+		}
+		os.Remove(o.tmp)
+		o.tmp = ""
+	}
+}
+
+// rowCounter is a RowWriter that counts the data rows, after the header,
+// written to an OutputFile.
+type rowCounter struct {
+	w      RowWriter
+	file   *OutputFile
+	header bool
+}
+
+// Write writes a row, counting it if it isn't the header.
+func (r *rowCounter) Write(row []string) error {
+
+	if r.header {
+		r.file.Rows++
+	}
+	r.header = true
+
+	return r.w.Write(row)
+}
+
+// WriteManifest writes the --manifest file, if one is named.
+func WriteManifest() {
+
+	if *manifestFile == "" {
+		return
+	}
+
+	type entry struct {
+		Path   string `json:"path"`
+		Rows   int    `json:"rows"`
+		Bytes  int64  `json:"bytes"`
+		SHA256 string `json:"sha256"`
+	}
+
+	manifest := struct {
+		Created string  `json:"created"`
+		Files   []entry `json:"files"`
+	}{
+		Created: time.Now().UTC().Format(time.RFC3339),
+		Files:   []entry{},
+	}
+
+	for _, o := range outputs {
+		manifest.Files = append(manifest.Files, entry{
+			Path:   o.Name,
+			Rows:   o.Rows,
+			Bytes:  o.Bytes,
+			SHA256: hex.EncodeToString(o.hash.Sum(nil)),
+		})
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err == nil {
+		err = os.WriteFile(*manifestFile, append(data, '\n'), 0644)
+	}
+	if err != nil {
+		fail(&RunError{Class: errIO, File: *manifestFile, Message: fmt.Sprintf("cannot write manifest: %v", err)})
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+var (
+	minMatchRateOpts listFlag
+	matchRateAction  = flag.String("match-rate-action", "fail", "what a --min-match-rate shortfall does: fail or warn")
+)
+
+func init() {
+	flag.Var(&minMatchRateOpts, "min-match-rate", "check that at least this share of the first input's keys are found in another input, as file2=95%. may be repeated")
+}
+
+// CheckMatchRates applies --min-match-rate once every input is read: for
+// each input named, the share of the first input's keys also found in it
+// must reach the threshold. A sudden shortfall usually means a broken
+// extract rather than real data.
+func CheckMatchRates(allData []DataCollection) {
+
+	if len(minMatchRateOpts) == 0 {
+		return
+	}
+
+	switch *matchRateAction {
+	case "fail", "warn":
+	default:
+		usagef("--match-rate-action %s must be fail or warn", *matchRateAction)
+	}
+
+	for _, opt := range minMatchRateOpts {
+
+		file, spec, ok := splitPair(opt, "=")
+		if !ok {
+			usagef("--min-match-rate %s must be file=percentage", opt)
+		}
+		i := FileIndex(inputNames, file)
+		if i < 0 {
+			// A --pipeline or --plan stage joins only some of the inputs.
+			if *pipelineOpt != "" || *planOpt != "" {
+				continue
+			}
+			usagef("--min-match-rate names %s, which is not an input file", file)
+		}
+
+		pct, err := strconv.ParseFloat(strings.TrimSuffix(spec, "%"), 64)
+		if err != nil || pct < 0 || pct > 100 {
+			usagef("--min-match-rate for %s must be a percentage, such as 95%%", inputNames[i])
+		}
+		if i == 0 {
+			usagef("--min-match-rate names %s, the first input, which the others are matched against", inputNames[i])
+		}
+
+		keys, found := 0, 0
+		for k := range allData[0].data {
+			if strings.HasPrefix(k, separateKeyPrefix) {
+				continue
+			}
+			keys++
+			if len(allData[i].data[k]) > 0 {
+				found++
+			}
+		}
+		if keys == 0 {
+			continue
+		}
+
+		rate := 100 * float64(found) / float64(keys)
+		if rate >= pct {
+			continue
+		}
+
+		msg := fmt.Sprintf("only %d of %d keys of %s (%.1f%%) are found in %s, below --min-match-rate %s", found, keys, inputNames[0], rate, inputNames[i], spec)
+		if *matchRateAction == "warn" {
+			log.Print(msg)
+			continue
+		}
+		fail(&RunError{
+			Class:   errPolicy,
+			File:    inputNames[i],
+			Message: msg,
+			Hint:    "an input may be incomplete or its keys formatted differently; check the extract, or use --match-rate-action warn",
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"io"
+	"runtime"
+	"runtime/debug"
+)
+
+var maxMemory = flag.String("max-memory", "", "soft memory limit for the Go runtime (e.g. 4GB). the garbage collector works harder as the limit nears")
+
+// sourceReader is a CSV reader over an opened input, keeping hold of the file
+// so it can be closed once read. With a --record-terminator, term is its
+// byte and terminated is set.
+type sourceReader struct {
+	*csv.Reader
+	io.Closer
+	term       byte
+	terminated bool
+}
+
+// Read reads the next record.
+func (s sourceReader) Read() ([]string, error) {
+
+	row, err := s.Reader.Read()
+	if s.terminated && row != nil {
+		restoreNewlines(row, s.term)
+	}
+
+	return row, err
+}
+
+// SetMemoryLimit applies --max-memory.
+func SetMemoryLimit() {
+
+	limit := parseByteSize("--max-memory", *maxMemory)
+	if limit > 0 {
+		debug.SetMemoryLimit(limit)
+	}
+}
+
+// releaseReader closes an input that has been read to the end and drops the
+// reader, so that its file handle and buffers are not kept for the rest of
+// the run.
+func releaseReader(readers []RowReader, i int) {
+
+	if c, ok := readers[i].(io.Closer); ok {
+		c.Close()
+	}
+
+	readers[i] = nil
+}
+
+// ReleaseScratch is called between loading the inputs and writing the output.
+// With --max-memory set it collects the garbage left by loading and returns
+// the freed memory to the operating system, so that the output phase starts
+// from the smallest footprint.
+func ReleaseScratch() {
+
+	if *maxMemory == "" {
+		return
+	}
+
+	runtime.GC()
+	debug.FreeOSMemory()
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+	"sort"
+)
+
+var noReorder = flag.Bool("no-reorder", false, "with three or more inputs, combine records in command line order instead of most selective input first")
+
+// sourceOrder, when set, is the order the DataCollections are combined in:
+// sourceOrder[k] is the original position of the k'th collection combined.
+var sourceOrder []int
+
+// ChooseJoinOrder orders the collections so that those with the fewest
+// records per key are combined first, which keeps the number of partial
+// combinations built on the way to each output row down. Ties keep their
+// command line order. Returns the collections in the chosen order.
+func ChooseJoinOrder(allData []DataCollection) []DataCollection {
+
+	sourceOrder = nil
+
+	if *noReorder || len(allData) < 3 {
+		return allData
+	}
+
+	fanOut := make([]float64, len(allData))
+	order := make([]int, len(allData))
+
+	for i, dc := range allData {
+		order[i] = i
+		records := 0
+		for _, recs := range dc.data {
+			records += len(recs)
+		}
+		if len(dc.data) > 0 {
+			fanOut[i] = float64(records) / float64(len(dc.data))
+		}
+	}
+
+	sort.SliceStable(order, func(a, b int) bool {
+		return fanOut[order[a]] < fanOut[order[b]]
+	})
+
+	reordered := make([]DataCollection, len(allData))
+	for k, i := range order {
+		reordered[k] = allData[i]
+	}
+
+	sourceOrder = order
+
+	return reordered
+}
+
+// restoreOrder puts a combination of records, built in sourceOrder, back in
+// command line order, so that which input's value wins for a shared column is
+// not affected by the reordering.
+func restoreOrder(recs []Record) []Record {
+
+	if sourceOrder == nil {
+		return recs
+	}
+
+	orig := make([]Record, len(recs))
+	for k, rec := range recs {
+		orig[sourceOrder[k]] = rec
+	}
+
+	return orig
+}
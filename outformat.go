@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"io"
+)
+
+var outputFormat = flag.String("format", "csv", "output format: csv, json (a single array of objects, one per row, keyed by column) or jsonl (one object per line)")
+
+// OutputEncoder encodes output rows. *csv.Writer is one.
+type OutputEncoder interface {
+	RowWriter
+	Flush()
+	Error() error
+}
+
+// NewOutputEncoder returns the encoder for --format, writing to w.
+func NewOutputEncoder(w io.Writer) OutputEncoder {
+
+	_, terminated := OutputTerminator()
+
+	switch *outputFormat {
+	case "csv":
+		out := csv.NewWriter(TerminatedWriter(w))
+		out.UseCRLF = *crlf && !terminated
+		return out
+	case "json", "jsonl":
+	default:
+		usagef("--format %s must be csv, json or jsonl", *outputFormat)
+	}
+
+	if *headerComments {
+		usagef("--header-comments only applies to csv output")
+	}
+	if terminated {
+		usagef("--output-record-terminator only applies to csv output")
+	}
+
+	return &jsonWriter{w: bufio.NewWriter(w), array: *outputFormat == "json"}
+}
+
+// jsonWriter writes rows as JSON objects keyed by the header's columns, in
+// the header's order: each on its own line for jsonl, or as the elements of
+// a single array for json. Rows are written as they come, so the array is
+// never held in memory; Flush closes it.
+type jsonWriter struct {
+	w      *bufio.Writer
+	array  bool
+	header [][]byte
+	rows   int
+	done   bool
+	err    error
+}
+
+// Write writes a row. The first row is the header, which names the fields.
+func (j *jsonWriter) Write(row []string) error {
+
+	if j.err != nil {
+		return j.err
+	}
+
+	if j.header == nil {
+		j.header = make([][]byte, len(row))
+		for i, col := range row {
+			j.header[i], _ = json.Marshal(col)
+		}
+		return nil
+	}
+
+	switch {
+	case j.array && j.rows == 0:
+		j.w.WriteString("[\n")
+	case j.array:
+		j.w.WriteString(",\n")
+	}
+	j.rows++
+
+	j.w.WriteByte('{')
+	for i, v := range row {
+		if i >= len(j.header) {
+			break
+		}
+		if i > 0 {
+			j.w.WriteByte(',')
+		}
+		value, _ := json.Marshal(v)
+		j.w.Write(j.header[i])
+		j.w.WriteByte(':')
+		j.w.Write(value)
+	}
+	// The buffer is written out as it fills, and once a write fails every
+	// later one reports the failure.
+	j.err = j.w.WriteByte('}')
+	if !j.array && j.err == nil {
+		j.err = j.w.WriteByte('\n')
+	}
+
+	return j.err
+}
+
+// Flush ends the array, for json, and writes out anything buffered.
+func (j *jsonWriter) Flush() {
+
+	if j.array && !j.done && j.err == nil {
+		j.done = true
+		if j.rows == 0 {
+			j.w.WriteString("[]\n")
+		} else {
+			j.w.WriteString("\n]\n")
+		}
+	}
+
+	if err := j.w.Flush(); err != nil && j.err == nil {
+		j.err = err
+	}
+}
+
+// Error returns the first error met writing.
+func (j *jsonWriter) Error() error {
+	return j.err
+}
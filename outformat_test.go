@@ -0,0 +1,33 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+)
+
+func TestJSONFieldsFollowColumnOrder(t *testing.T) {
+
+	tests := []struct {
+		array bool
+		want  string
+	}{
+		{false, "{\"zone\":\"n\",\"amount\":\"1.5\",\"id\":\"7\"}\n{\"zone\":\"s\",\"amount\":\"\",\"id\":\"8\"}\n"},
+		{true, "[\n{\"zone\":\"n\",\"amount\":\"1.5\",\"id\":\"7\"},\n{\"zone\":\"s\",\"amount\":\"\",\"id\":\"8\"}\n]\n"},
+	}
+
+	for _, tt := range tests {
+		buf := &bytes.Buffer{}
+		j := &jsonWriter{w: bufio.NewWriter(buf), array: tt.array}
+		for _, row := range [][]string{{"zone", "amount", "id"}, {"n", "1.5", "7"}, {"s", "", "8"}} {
+			if err := j.Write(row); err != nil {
+				t.Fatal(err)
+			}
+		}
+		j.Flush()
+
+		if got := buf.String(); got != tt.want {
+			t.Errorf("array %v: wrote %q, want %q", tt.array, got, tt.want)
+		}
+	}
+}
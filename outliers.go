@@ -0,0 +1,160 @@
+package main
+
+import (
+	"flag"
+	"math"
+	"strconv"
+)
+
+var outlierOpts listFlag
+
+func init() {
+	flag.Var(&outlierOpts, "flag-outliers", "add a <col>_outlier column marking values more than N standard deviations from the column's mean over all inputs, as col=zscore:N. may be repeated")
+}
+
+// outlierRule is one --flag-outliers option.
+type outlierRule struct {
+	column    string
+	threshold float64
+}
+
+// columnStats keeps a running mean and variance (Welford's method) of a
+// column's numeric values.
+type columnStats struct {
+	n    int
+	mean float64
+	m2   float64
+}
+
+// outlierStats holds the statistics of each --flag-outliers column, gathered
+// as the inputs are read.
+var outlierStats map[string]*columnStats
+
+// Add includes a value in the statistics.
+func (c *columnStats) Add(v float64) {
+
+	c.n++
+	d := v - c.mean
+	c.mean += d / float64(c.n)
+	c.m2 += d * (v - c.mean)
+}
+
+// ZScore returns how many standard deviations v is from the mean, or 0 if
+// the values read don't vary.
+func (c *columnStats) ZScore(v float64) float64 {
+
+	if c.n < 2 || c.m2 == 0 {
+		return 0
+	}
+
+	return math.Abs(v-c.mean) / math.Sqrt(c.m2/float64(c.n))
+}
+
+// outlierRules parses --flag-outliers.
+func outlierRules() []outlierRule {
+
+	rules := []outlierRule{}
+
+	for _, spec := range outlierOpts {
+		col, method, ok := splitPair(spec, "=")
+		if !ok {
+			usagef("--flag-outliers %s must be col=zscore:N", spec)
+		}
+		name, n, ok := splitPair(method, ":")
+		threshold, err := strconv.ParseFloat(n, 64)
+		if !ok || name != "zscore" || err != nil || threshold <= 0 {
+			usagef("--flag-outliers %s must be col=zscore:N, with N a positive number", spec)
+		}
+		rules = append(rules, outlierRule{column: col, threshold: threshold})
+	}
+
+	return rules
+}
+
+// OutlierObserver returns the function adding a record's values to the
+// --flag-outliers statistics, for an input with the given headers, or nil if
+// none of its columns are flagged.
+func OutlierObserver(headers []string) func(Record) {
+
+	cols := []string{}
+	for _, rule := range outlierRules() {
+		if contains(headers, rule.column) {
+			cols = append(cols, rule.column)
+		}
+	}
+
+	if len(cols) == 0 {
+		return nil
+	}
+
+	if outlierStats == nil {
+		outlierStats = map[string]*columnStats{}
+	}
+	for _, col := range cols {
+		if outlierStats[col] == nil {
+			outlierStats[col] = &columnStats{}
+		}
+	}
+
+	return func(rec Record) {
+		for _, col := range cols {
+			if v, ok := parseNumber(rec[col]); ok {
+				outlierStats[col].Add(v)
+			}
+		}
+	}
+}
+
+// OutlierFlagger works out the --flag-outliers columns of output rows.
+type OutlierFlagger struct {
+	rules   []outlierRule
+	columns []int
+}
+
+// NewOutlierFlagger parses --flag-outliers against the output header,
+// returning nil if it is not set.
+func NewOutlierFlagger(header []string) *OutlierFlagger {
+
+	rules := outlierRules()
+	if len(rules) == 0 {
+		return nil
+	}
+
+	f := &OutlierFlagger{rules: rules}
+
+	for _, rule := range rules {
+		i := indexOf(header, rule.column)
+		if i < 0 {
+			usagef("--flag-outliers column %s is not an output column", rule.column)
+		}
+		f.columns = append(f.columns, i)
+	}
+
+	return f
+}
+
+// Header returns the names of the added columns.
+func (f *OutlierFlagger) Header() []string {
+
+	names := []string{}
+	for _, rule := range f.rules {
+		names = append(names, rule.column+"_outlier")
+	}
+
+	return names
+}
+
+// Flags returns the added columns for a row: true for each value that is an
+// outlier, false otherwise, including for values that aren't numbers.
+func (f *OutlierFlagger) Flags(row []string) []string {
+
+	flags := make([]string, len(f.rules))
+
+	for i, rule := range f.rules {
+		v, ok := parseNumber(row[f.columns[i]])
+		stats := outlierStats[rule.column]
+		flags[i] = boolString(ok && stats != nil && stats.ZScore(v) > rule.threshold)
+	}
+
+	return flags
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+)
+
+// OutputWriter applies the output options (derived columns, write-time
+// substitutions, column formatting, hashing, outlier flags, constant columns
+// and duplicate counting) to the rows written through it. The first row
+// written must be the header.
+type OutputWriter struct {
+	w        RowWriter
+	sink     RowWriter
+	dups     *dupCounter
+	stages   []func(row []string)
+	strat    *Stratifier
+	outliers *OutlierFlagger
+	consts   []string
+	derive   *Deriver
+	started  bool
+
+	// Preamble, if set, is called just before the header is written.
+	Preamble func() error
+}
+
+// NewOutputWriter wraps w with the output options.
+func NewOutputWriter(w RowWriter) *OutputWriter {
+
+	o := &OutputWriter{w: w, sink: w}
+
+	if *countDuplicates != "" {
+		o.dups = NewDupCounter(w, *countDuplicates)
+		o.w = o.dups
+	}
+
+	return o
+}
+
+// Write writes a row. The header is used to set up the per-column options.
+func (o *OutputWriter) Write(row []string) error {
+
+	if !o.started {
+		o.started = true
+		if o.Preamble != nil {
+			if err := o.Preamble(); err != nil {
+				return err
+			}
+		}
+		o.derive = NewDeriver(row)
+		if o.derive != nil {
+			row = append(row, o.derive.names...)
+		}
+		for _, stage := range []func([]string){RowReplacer(row), RowFormatter(row), RowHasher(row)} {
+			if stage != nil {
+				o.stages = append(o.stages, stage)
+			}
+		}
+		o.strat = NewStratifier(row)
+		if o.strat != nil {
+			row = append(row, row[o.strat.column]+"_bucket")
+		}
+		o.outliers = NewOutlierFlagger(row)
+		if o.outliers != nil {
+			row = append(row, o.outliers.Header()...)
+		}
+		var names []string
+		names, o.consts = ConstColumns(row)
+		row = append(row, names...)
+		return o.w.Write(row)
+	}
+
+	if o.derive != nil {
+		row = o.derive.Derive(row)
+	}
+
+	// The bucket and outlier flags are worked out from the values before
+	// they are formatted or hashed.
+	bucket := 0
+	if o.strat != nil {
+		bucket = o.strat.Bucket(row)
+	}
+	var flags []string
+	if o.outliers != nil {
+		flags = o.outliers.Flags(row)
+	}
+
+	for _, stage := range o.stages {
+		stage(row)
+	}
+
+	if o.strat == nil {
+		row = append(row, flags...)
+		return o.w.Write(append(row, o.consts...))
+	}
+
+	row = append(row, o.strat.Label(bucket))
+	row = append(row, flags...)
+	row = append(row, o.consts...)
+
+	if o.strat.sample > 0 {
+		o.strat.Offer(bucket, row)
+		return nil
+	}
+
+	return o.w.Write(row)
+}
+
+// Sink returns the writer under the output options, for output such as the
+// --venn summary that they don't apply to. The Preamble is written first.
+func (o *OutputWriter) Sink() (RowWriter, error) {
+
+	if !o.started {
+		o.started = true
+		if o.Preamble != nil {
+			if err := o.Preamble(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return o.sink, nil
+}
+
+// Flush writes anything held back, such as sampled rows and counted
+// duplicates.
+func (o *OutputWriter) Flush() {
+
+	var err error
+
+	if o.strat != nil && o.strat.sample > 0 {
+		for _, row := range o.strat.Sampled() {
+			if err == nil {
+				err = o.w.Write(row)
+			}
+		}
+	}
+
+	if err == nil && o.dups != nil {
+		err = o.dups.Flush()
+	}
+
+	if err != nil {
+		fail(&RunError{Class: errOutput, Message: fmt.Sprintf("failed to write CSV output: %v", err)})
+	}
+}
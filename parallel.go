@@ -0,0 +1,93 @@
+package main
+
+import (
+	"flag"
+	"hash/fnv"
+	"sync"
+)
+
+var parallel = flag.Int("parallel", 1, "produce output rows with this many goroutines, each working on a hash partition of the keys. output order is unchanged")
+
+// keyBatch is the output of one key, produced by a shard.
+type keyBatch struct {
+	rows     [][]string
+	complete bool
+}
+
+// WriteKeysParallel writes the output for keys, as the serial loop in Join
+// does, but with the rows built by n goroutines. Each key belongs to one
+// shard by the hash of the key; a shard works through its keys in output
+// order, and the rows are written by merging the shards back in that order,
+// so the output is the same as a serial run. Writing, and everything else
+// that keeps counts, stays on the calling goroutine.
+func WriteKeysParallel(keys []string, outputColumns []string, allData []DataCollection, n int) {
+
+	shardOf := func(key string) int {
+		h := fnv.New32a()
+		h.Write([]byte(key))
+		return int(h.Sum32() % uint32(n))
+	}
+
+	done := make(chan struct{})
+	shards := make([]chan keyBatch, n)
+	var wg sync.WaitGroup
+
+	for s := range shards {
+		shards[s] = make(chan keyBatch, 64)
+		wg.Add(1)
+		go func(s int) {
+			defer wg.Done()
+			defer close(shards[s])
+			working := ""
+			defer func() {
+				if r := recover(); r != nil {
+					crashed(r, working)
+				}
+			}()
+			for _, key := range keys {
+				if shardOf(key) != s || keyDeleted(key) {
+					continue
+				}
+				working = key
+				b := keyBatch{}
+				b.complete = KeyRows(key, outputColumns, allData, rowLimit, func(row []string) {
+					b.rows = append(b.rows, row)
+				})
+				select {
+				case shards[s] <- b:
+				case <-done:
+					return
+				}
+			}
+		}(s)
+	}
+
+	defer wg.Wait()
+	defer close(done)
+
+	for _, key := range keys {
+
+		if rowLimit > 0 && rowsWritten >= rowLimit {
+			return
+		}
+		if keyDeleted(key) {
+			dropKeyRows(key, allData, "key deleted by a tombstone")
+			continue
+		}
+
+		b := <-shards[shardOf(key)]
+
+		if !b.complete {
+			dropKeyRows(key, allData, "key skipped by --key-timeout")
+			SkipKey(key)
+			continue
+		}
+
+		for _, row := range b.rows {
+			if rowLimit > 0 && rowsWritten >= rowLimit {
+				return
+			}
+			WriteRow(row)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"sort"
+)
+
+var onParseErrorOpts listFlag
+
+func init() {
+	flag.Var(&onParseErrorOpts, "on-parse-error", "what to do when a typed column's value can't be read, as col=skip (drop the row), col=null (use an empty value) or col=fail (the default). may be repeated")
+}
+
+// Coercion applies the --on-parse-error policies to the typed values read
+// from one input.
+type Coercion struct {
+	file     string
+	policies map[string]string
+	nulled   map[string]int
+}
+
+// NewCoercion returns the Coercion for an input.
+func NewCoercion(file string) *Coercion {
+
+	c := &Coercion{file: file, policies: map[string]string{}, nulled: map[string]int{}}
+
+	for _, spec := range onParseErrorOpts {
+		col, policy, ok := splitPair(spec, "=")
+		if !ok {
+			usagef("--on-parse-error %s must be col=skip, col=null or col=fail", spec)
+		}
+		switch policy {
+		case "skip", "null", "fail":
+		default:
+			usagef("--on-parse-error policy %s must be skip, null or fail", policy)
+		}
+		c.policies[col] = policy
+	}
+
+	return c
+}
+
+// Failed handles a value of col that could not be read, as described by e.
+// It fails the run under the fail policy. Otherwise it returns true if the
+// row should be skipped, or false if the value should be taken as empty.
+func (c *Coercion) Failed(col string, e *RunError) bool {
+
+	switch c.policies[col] {
+	case "skip":
+		CountDrop(c.file, "unreadable "+col+" value (--on-parse-error skip)", 1)
+		return true
+	case "null":
+		c.nulled[col]++
+		return false
+	}
+
+	e.Hint += ". --on-parse-error " + col + "=skip or =null would carry on past it"
+	fail(e)
+
+	return true
+}
+
+// Report logs how many values were taken as empty.
+func (c *Coercion) Report() {
+
+	cols := []string{}
+	for col := range c.nulled {
+		cols = append(cols, col)
+	}
+	sort.Strings(cols)
+
+	for _, col := range cols {
+		log.Printf("%s: %d unreadable %s values were taken as empty", c.file, c.nulled[col], col)
+	}
+}
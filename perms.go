@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"io/fs"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+)
+
+var (
+	outputModeOpt  = flag.String("output-mode", "", "permissions for output files, in octal (e.g. 0640). the umask still applies")
+	outputOwnerOpt = flag.String("output-owner", "", "owner for output files, as user, user:group or :group")
+	fsyncOutput    = flag.Bool("fsync", false, "sync each output file to disk before renaming it into place")
+)
+
+// OutputMode returns the --output-mode permissions, 0666 if not set.
+func OutputMode() fs.FileMode {
+
+	if *outputModeOpt == "" {
+		return 0666
+	}
+
+	mode, err := strconv.ParseUint(*outputModeOpt, 8, 32)
+	if err != nil || mode > 0777 {
+		usagef("--output-mode %s must be octal permissions such as 0640", *outputModeOpt)
+	}
+
+	return fs.FileMode(mode)
+}
+
+// ChownOutput gives a new output file the --output-owner, if set.
+func ChownOutput(path string) error {
+
+	if *outputOwnerOpt == "" {
+		return nil
+	}
+
+	name, group, _ := strings.Cut(*outputOwnerOpt, ":")
+	uid, gid := -1, -1
+
+	if name != "" {
+		u, err := user.Lookup(name)
+		if err != nil {
+			return err
+		}
+		uid, _ = strconv.Atoi(u.Uid)
+	}
+
+	if group != "" {
+		g, err := user.LookupGroup(group)
+		if err != nil {
+			return err
+		}
+		gid, _ = strconv.Atoi(g.Gid)
+	}
+
+	return os.Chown(path, uid, gid)
+}
+
+// syncDir syncs a directory, so that a rename into it survives a crash. Not
+// every system can sync a directory, so failures are ignored.
+func syncDir(dir string) {
+
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+
+	d.Sync()
+	d.Close()
+}
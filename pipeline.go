@@ -0,0 +1,346 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+var (
+	pipelineOpt = flag.String("pipeline", "", "run a multi-stage plan such as 'join(a,b,on=id) | filter(x>0) | join(_,c,on=region) | select(id,x)'. _ is the previous stage's result")
+	planOpt     = flag.String("plan", "", "join in stages, each on its own key, as 'f1+f2:on=id; +f3:on=region'. a leading + joins onto the previous stage's result. without on= a stage joins on the columns its inputs share")
+)
+
+// Table is a header and rows held in memory.
+type Table struct {
+	Header []string
+	Rows   [][]string
+}
+
+// reader returns a RowReader that replays the table, header first.
+func (t *Table) reader() RowReader {
+
+	rows := make([][]string, 0, len(t.Rows)+1)
+	rows = append(rows, t.Header)
+	rows = append(rows, t.Rows...)
+
+	return &memReader{rows: rows}
+}
+
+// tableWriter is a RowWriter that collects rows into a Table. The first row is
+// the header.
+type tableWriter struct {
+	t *Table
+}
+
+// Write adds a row to the table.
+func (w *tableWriter) Write(row []string) error {
+
+	if w.t.Header == nil {
+		w.t.Header = row
+		return nil
+	}
+
+	w.t.Rows = append(w.t.Rows, row)
+
+	return nil
+}
+
+// pipelineStage is one step of a --pipeline plan: its operation, the raw text
+// between the parentheses, and that text split into arguments.
+type pipelineStage struct {
+	op   string
+	body string
+	args []string
+}
+
+// ParsePipeline splits a plan into stages.
+func ParsePipeline(src string) []pipelineStage {
+
+	stages := []pipelineStage{}
+
+	for _, part := range splitTopLevel(src, '|') {
+
+		part = strings.TrimSpace(part)
+
+		open := strings.Index(part, "(")
+		if open <= 0 || !strings.HasSuffix(part, ")") {
+			usagef("--pipeline stage %q must look like op(args)", part)
+		}
+
+		body := part[open+1 : len(part)-1]
+		args := []string{}
+		for _, arg := range splitTopLevel(body, ',') {
+			if arg = strings.TrimSpace(arg); arg != "" {
+				args = append(args, arg)
+			}
+		}
+
+		stages = append(stages, pipelineStage{
+			op:   strings.TrimSpace(part[:open]),
+			body: body,
+			args: args,
+		})
+	}
+
+	return stages
+}
+
+// ParsePlan reads a --plan into the join stages of the equivalent pipeline:
+// 'f1+f2:on=id; +f3:on=region' is 'join(f1,f2,on=id) | join(_,f3,on=region)'.
+func ParsePlan(src string) []pipelineStage {
+
+	stages := []pipelineStage{}
+
+	for _, part := range strings.Split(src, ";") {
+
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		sources, opts, _ := strings.Cut(part, ":")
+		args := []string{}
+		if rest, ok := strings.CutPrefix(strings.TrimSpace(sources), "+"); ok {
+			args = append(args, "_")
+			sources = rest
+		}
+		for _, name := range strings.Split(sources, "+") {
+			if name = strings.TrimSpace(name); name == "" {
+				usagef("--plan stage %q names an empty input", part)
+			}
+			args = append(args, strings.TrimSpace(name))
+		}
+
+		if len(stages) > 0 && !contains(args, "_") {
+			usagef("--plan stage %q must start with + to join onto the previous stage", part)
+		}
+
+		if opts = strings.TrimSpace(opts); opts != "" {
+			cols, ok := strings.CutPrefix(opts, "on=")
+			if !ok || len(parseColumnList(cols)) == 0 {
+				usagef("--plan stage %q: after the : give on=col,...", part)
+			}
+			args = append(args, "on=("+cols+")")
+		}
+
+		stages = append(stages, pipelineStage{op: "join", args: args})
+	}
+
+	return stages
+}
+
+// splitTopLevel splits s at sep, ignoring separators inside parentheses or
+// quotes. A doubled separator (as in ||) is not split.
+func splitTopLevel(s string, sep byte) []string {
+
+	parts := []string{}
+	depth := 0
+	var quote byte
+	start := 0
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == sep && depth == 0:
+			if i+1 < len(s) && s[i+1] == sep {
+				i++
+				continue
+			}
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(parts, s[start:])
+}
+
+// RunPipeline loads all the inputs and runs the --pipeline or --plan over
+// them, writing the final stage's result to w.
+func RunPipeline(readers []RowReader, fileNames []string, w RowWriter) {
+
+	inputs := []*Table{}
+	for _, rows := range LoadAll(readers, fileNames) {
+		t := &Table{}
+		if len(rows) > 0 {
+			t.Header, t.Rows = rows[0], rows[1:]
+		}
+		inputs = append(inputs, t)
+	}
+
+	if *pipelineOpt != "" && *planOpt != "" {
+		usagef("use either --pipeline or --plan, not both")
+	}
+
+	stages := ParsePlan(*planOpt)
+	if *pipelineOpt != "" {
+		stages = ParsePipeline(*pipelineOpt)
+	}
+
+	var cur *Table
+
+	source := func(name string) (*Table, string) {
+		if name == "_" {
+			if cur == nil {
+				usagef("--pipeline: _ used before any stage has produced a result")
+			}
+			return cur, "previous stage"
+		}
+		i := FileIndex(fileNames, name)
+		if i < 0 {
+			usagef("--pipeline names %s, which is not an input file", name)
+		}
+		return inputs[i], fileNames[i]
+	}
+
+	for _, stage := range stages {
+
+		switch stage.op {
+
+		case "join":
+			sources := []string{}
+			var on []string
+			for _, arg := range stage.args {
+				if k, v, ok := splitPair(arg, "="); ok && k == "on" {
+					on = parseColumnList(v)
+					continue
+				}
+				sources = append(sources, arg)
+			}
+			if len(sources) < 2 {
+				usagef("--pipeline join needs at least two sources")
+			}
+			tables, names := []*Table{}, []string{}
+			for _, src := range sources {
+				t, name := source(src)
+				tables, names = append(tables, t), append(names, name)
+			}
+			cur = JoinTables(tables, names, on)
+
+		case "filter":
+			if cur == nil {
+				usagef("--pipeline filter needs a previous stage")
+			}
+			expr, err := CompileExpr(stage.body, cur.Header)
+			if err != nil {
+				usagef("--pipeline filter: %v", err)
+			}
+			cur = FilterTable(cur, expr)
+
+		case "select":
+			if cur == nil {
+				usagef("--pipeline select needs a previous stage")
+			}
+			cur = SelectColumns(cur, stage.args)
+
+		default:
+			usagef("--pipeline has unknown stage %s. use join, filter or select", stage.op)
+		}
+	}
+
+	if cur == nil {
+		usagef("--pipeline is empty")
+	}
+
+	err := w.Write(cur.Header)
+	for i := 0; err == nil && i < len(cur.Rows); i++ {
+		if rowLimit > 0 && i >= rowLimit {
+			break
+		}
+		err = w.Write(cur.Rows[i])
+	}
+	if err != nil {
+		fail(&RunError{Class: errOutput, Message: fmt.Sprintf("failed to write CSV output: %v", err)})
+	}
+}
+
+// parseColumnList reads a column list written as a or (a,b).
+func parseColumnList(s string) []string {
+
+	s = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(s), "("), ")")
+
+	cols := []string{}
+	for _, col := range strings.Split(s, ",") {
+		if col = strings.TrimSpace(col); col != "" {
+			cols = append(cols, col)
+		}
+	}
+
+	return cols
+}
+
+// JoinTables joins tables held in memory, on the named columns or, if on is
+// empty, on the columns they have in common.
+func JoinTables(tables []*Table, names []string, on []string) *Table {
+
+	defer func(savedKeys []string, savedLimit int, savedNames []string) {
+		keyColumns, rowLimit, inputNames = savedKeys, savedLimit, savedNames
+	}(keyColumns, rowLimit, inputNames)
+
+	keyColumns = on
+	rowLimit = 0
+
+	readers := []RowReader{}
+	for _, t := range tables {
+		readers = append(readers, t.reader())
+	}
+
+	result := &Table{}
+	Join(readers, names, &tableWriter{t: result})
+
+	return result
+}
+
+// FilterTable keeps the rows for which expr is true.
+func FilterTable(t *Table, expr *Expr) *Table {
+
+	result := &Table{Header: t.Header}
+
+	for _, row := range t.Rows {
+		get := func(col string) string {
+			return row[indexOf(t.Header, col)]
+		}
+		if expr.Match(get) {
+			result.Rows = append(result.Rows, row)
+		}
+	}
+
+	CountDrop("pipeline", "filter("+expr.String()+")", len(t.Rows)-len(result.Rows))
+
+	return result
+}
+
+// SelectColumns keeps only the named columns, in the order given.
+func SelectColumns(t *Table, cols []string) *Table {
+
+	positions := []int{}
+	for _, col := range cols {
+		i := indexOf(t.Header, col)
+		if i < 0 {
+			usagef("--pipeline select: unknown column %s", col)
+		}
+		positions = append(positions, i)
+	}
+
+	result := &Table{Header: cols}
+
+	for _, row := range t.Rows {
+		out := make([]string, len(positions))
+		for j, i := range positions {
+			out[j] = row[i]
+		}
+		result.Rows = append(result.Rows, out)
+	}
+
+	return result
+}
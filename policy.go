@@ -0,0 +1,149 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var (
+	columnTags    listFlag
+	hashColumns   = flag.String("hash", "", "comma separated output columns to replace with the hex SHA-256 of their values")
+	enforcePolicy = flag.Bool("enforce-policy", false, "refuse to write columns tagged pii or confidential unless they are hashed")
+)
+
+func init() {
+	flag.Var(&columnTags, "tag", "classify a column, as col=pii or col=confidential. may be repeated")
+}
+
+// restrictedTags are the classifications --enforce-policy protects.
+var restrictedTags = map[string]bool{"pii": true, "confidential": true}
+
+// ColumnTags returns the classification of each tagged column.
+func ColumnTags() map[string]string {
+
+	tags := map[string]string{}
+
+	for _, spec := range columnTags {
+		col, tag, ok := splitPair(spec, "=")
+		if !ok {
+			usagef("--tag %s must be col=tag", spec)
+		}
+		tags[col] = strings.ToLower(tag)
+	}
+
+	return tags
+}
+
+// RowHasher checks the output columns against the column policy and builds the
+// function that hashes the --hash columns, or returns nil if there are none.
+// The --emit-key column holds the join column values, so it counts as tagged
+// when a join column is.
+func RowHasher(outputColumns []string) func(row []string) {
+
+	hashed := map[int]bool{}
+
+	for _, col := range strings.Split(*hashColumns, ",") {
+		if col == "" {
+			continue
+		}
+		i := indexOf(outputColumns, col)
+		if i < 0 {
+			usagef("--hash column %s is not an output column", col)
+		}
+		hashed[i] = true
+	}
+
+	if *enforcePolicy {
+		exposed := []string{}
+		for col, tag := range ColumnTags() {
+			i := indexOf(outputColumns, col)
+			if i >= 0 && restrictedTags[tag] && !hashed[i] {
+				exposed = append(exposed, fmt.Sprintf("%s (%s)", col, tag))
+			}
+		}
+		if i := indexOf(outputColumns, *emitKey); *emitKey != "" && i >= 0 && !hashed[i] {
+			if tagged := restrictedKeyColumns(resolvedJoinColumns); len(tagged) > 0 {
+				exposed = append(exposed, fmt.Sprintf("%s (the key of %s)", *emitKey, strings.Join(tagged, ", ")))
+			}
+		}
+		if len(exposed) > 0 {
+			sort.Strings(exposed)
+			fail(&RunError{
+				Class:   errPolicy,
+				Message: fmt.Sprintf("policy forbids writing tagged columns: %s", strings.Join(exposed, ", ")),
+				Hint:    "hash them with --hash",
+			})
+		}
+	}
+
+	if len(hashed) == 0 {
+		return nil
+	}
+
+	return func(row []string) {
+		for i := range hashed {
+			if row[i] != "" {
+				row[i] = hashValue(row[i])
+			}
+		}
+	}
+}
+
+// hashValue returns the hex SHA-256 of v, as --hash writes it.
+func hashValue(v string) string {
+
+	sum := sha256.Sum256([]byte(v))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// restrictedKeyColumns returns the join columns --enforce-policy protects,
+// with their tags, or nil if it is not set.
+func restrictedKeyColumns(joinColumns []string) []string {
+
+	if !*enforcePolicy {
+		return nil
+	}
+
+	tags := ColumnTags()
+	tagged := []string{}
+	for _, col := range joinColumns {
+		if restrictedTags[tags[col]] {
+			tagged = append(tagged, fmt.Sprintf("%s (%s)", col, tags[col]))
+		}
+	}
+
+	return tagged
+}
+
+// CheckKeyPolicy refuses --audit-keys under --enforce-policy when a join
+// column is tagged, as the audit file holds the raw join column values.
+func CheckKeyPolicy(joinColumns []string) {
+
+	if *auditKeysFile == "" {
+		return
+	}
+
+	if tagged := restrictedKeyColumns(joinColumns); len(tagged) > 0 {
+		fail(&RunError{
+			Class:   errPolicy,
+			Message: fmt.Sprintf("policy forbids writing tagged join columns to --audit-keys: %s", strings.Join(tagged, ", ")),
+			Hint:    "leave out --audit-keys, or join on untagged columns",
+		})
+	}
+}
+
+// policyKey is a key as reports outside the output may show it: hashed under
+// --enforce-policy when a join column is tagged.
+func policyKey(key string) string {
+
+	if len(restrictedKeyColumns(resolvedJoinColumns)) > 0 {
+		return "sha256:" + hashValue(key)
+	}
+
+	return key
+}
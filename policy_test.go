@@ -0,0 +1,35 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestKeyPolicy(t *testing.T) {
+
+	defer func(enforce bool, tags listFlag, emit, hash string, resolved []string) {
+		*enforcePolicy, columnTags, *emitKey, *hashColumns, resolvedJoinColumns = enforce, tags, emit, hash, resolved
+	}(*enforcePolicy, columnTags, *emitKey, *hashColumns, resolvedJoinColumns)
+
+	columnTags = listFlag{"email=pii"}
+	resolvedJoinColumns = []string{"email"}
+	*emitKey = "key"
+	*hashColumns = "email,key"
+
+	*enforcePolicy = false
+	if got := policyKey("a@example.com"); got != "a@example.com" {
+		t.Errorf("without --enforce-policy, policyKey = %q", got)
+	}
+
+	*enforcePolicy = true
+	if got := policyKey("a@example.com"); !strings.HasPrefix(got, "sha256:") || strings.Contains(got, "example") {
+		t.Errorf("with a pii join column, policyKey = %q, want it hashed", got)
+	}
+
+	// A hashed --emit-key column passes the policy, and is hashed.
+	row := []string{"a@example.com", "a@example.com"}
+	RowHasher([]string{"email", "key"})(row)
+	if row[1] != hashValue("a@example.com") {
+		t.Errorf("emitted key written as %q, want it hashed", row[1])
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"flag"
+	"sync"
+)
+
+var reuseBuffers = flag.Bool("reuse-buffers", false, "reuse row buffers and records while reading inputs and writing output, cutting allocations and garbage collection on large joins")
+
+var (
+	// rowPool holds output row slices between uses.
+	rowPool = sync.Pool{
+		New: func() any {
+			return []string(nil)
+		},
+	}
+
+	// rowPooling is set while output rows can be returned to rowPool after
+	// they are written: --reuse-buffers is on and the writer doesn't keep
+	// the rows it is given.
+	rowPooling bool
+
+	// recordPool holds input records between uses: those of rows dropped
+	// while reading, and those of keys already written.
+	recordPool = sync.Pool{
+		New: func() any {
+			return Record(nil)
+		},
+	}
+)
+
+// newRecord returns an empty record with room for n columns, reusing a
+// released one with --reuse-buffers.
+func newRecord(n int) Record {
+
+	if !*reuseBuffers {
+		return make(Record, n)
+	}
+
+	rec := recordPool.Get().(Record)
+	if rec == nil {
+		return make(Record, n)
+	}
+
+	return rec
+}
+
+// putRecord hands back a record that nothing holds on to any more.
+func putRecord(rec Record) {
+
+	if !*reuseBuffers || rec == nil {
+		return
+	}
+
+	clear(rec)
+	recordPool.Put(rec)
+}
+
+// releaseKey hands back the records of a key once its rows are written.
+func releaseKey(key string, allData []DataCollection) {
+
+	if !*reuseBuffers {
+		return
+	}
+
+	for _, dc := range allData {
+		for _, rec := range dc.data[key] {
+			putRecord(rec)
+		}
+		delete(dc.data, key)
+	}
+}
+
+// newRow returns an empty row with room for n values, reusing a written row
+// if pooling.
+func newRow(n int) []string {
+
+	if !rowPooling {
+		return make([]string, 0, n)
+	}
+
+	row := rowPool.Get().([]string)
+	if cap(row) < n {
+		return make([]string, 0, n)
+	}
+
+	return row[:0]
+}
+
+// putRow hands a written row back for reuse.
+func putRow(row []string) {
+
+	if !rowPooling {
+		return
+	}
+
+	clear(row)
+	rowPool.Put(row[:0])
+}
+
+// reuseInputRows lets an input's CSV reader reuse its row slice from one Read
+// to the next. Only safe once nothing holds on to rows from that reader, so it
+// is applied after the header has been read, and only to readers that read
+// straight from a file, or through a reader that replays rows read ahead.
+func reuseInputRows(reader RowReader) {
+
+	if !*reuseBuffers {
+		return
+	}
+
+	for {
+		u, ok := reader.(interface{ Unwrap() RowReader })
+		if !ok {
+			break
+		}
+		reader = u.Unwrap()
+	}
+
+	if sr, ok := reader.(sourceReader); ok {
+		sr.ReuseRecord = true
+	}
+}
+
+// poolingFor reports whether output rows written to w can be pooled.
+func poolingFor(w RowWriter) bool {
+
+	_, keeps := w.(*tableWriter)
+
+	return *reuseBuffers && !keeps
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// discardRows is a RowWriter which drops the rows written to it.
+type discardRows struct{}
+
+// Write drops row.
+func (discardRows) Write(row []string) error {
+	return nil
+}
+
+// benchInput returns a CSV document of n rows keyed by id.
+func benchInput(n int, col string) string {
+
+	sb := strings.Builder{}
+	fmt.Fprintf(&sb, "id,region,%s\n", col)
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&sb, "%d,r%d,%s%d\n", i, i%10, col, i)
+	}
+
+	return sb.String()
+}
+
+func BenchmarkJoin(b *testing.B) {
+
+	const rows = 10000
+	left, right := benchInput(rows, "left"), benchInput(rows, "right")
+
+	for _, reuse := range []bool{false, true} {
+		b.Run(fmt.Sprintf("reuse-buffers=%v", reuse), func(b *testing.B) {
+
+			defer func(old bool) { *reuseBuffers = old }(*reuseBuffers)
+			*reuseBuffers = reuse
+
+			b.ReportAllocs()
+			var before, after runtime.MemStats
+			runtime.ReadMemStats(&before)
+
+			for i := 0; i < b.N; i++ {
+				readers := []RowReader{}
+				for _, in := range []string{left, right} {
+					readers = append(readers, sourceReader{Reader: csv.NewReader(strings.NewReader(in)), Closer: io.NopCloser(nil)})
+				}
+				Run(readers, []string{"left.csv", "right.csv"}, discardRows{})
+			}
+
+			runtime.ReadMemStats(&after)
+			b.ReportMetric(float64(after.Mallocs-before.Mallocs)/float64(b.N*rows), "allocs/row")
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"sort"
+	"strings"
+)
+
+var prefixMatch = flag.String("prefix-match", "", "name an input whose join keys are prefixes: a key of another input matches the longest of its keys that the key starts with, as for phone number prefixes or SKU families. needs a single join column")
+
+// prefixIndex finds the longest of a set of keys that a key starts with. It
+// looks up each distinct key length, longest first, rather than comparing
+// the key with every prefix.
+type prefixIndex struct {
+	keys    map[string]bool
+	lengths []int
+}
+
+// newPrefixIndex indexes the keys of a collection.
+func newPrefixIndex(dc DataCollection) *prefixIndex {
+
+	p := &prefixIndex{keys: map[string]bool{}}
+	seen := map[int]bool{}
+
+	for k := range dc.data {
+		if k == "" || strings.HasPrefix(k, separateKeyPrefix) {
+			continue
+		}
+		p.keys[k] = true
+		if !seen[len(k)] {
+			seen[len(k)] = true
+			p.lengths = append(p.lengths, len(k))
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(p.lengths)))
+
+	return p
+}
+
+// Longest returns the longest indexed key that key starts with, or false if
+// there is none.
+func (p *prefixIndex) Longest(key string) (string, bool) {
+
+	for _, n := range p.lengths {
+		if n <= len(key) && p.keys[key[:n]] {
+			return key[:n], true
+		}
+	}
+
+	return "", false
+}
+
+// PrefixMerge applies --prefix-match, moving the records of every other
+// input to the longest key of the prefix input that their key starts with.
+// Keys that start with no prefix are left as they are.
+func PrefixMerge(allData []DataCollection, joinColumns []string) []DataCollection {
+
+	if *prefixMatch == "" {
+		return allData
+	}
+
+	src := FileIndex(inputNames, *prefixMatch)
+	if src < 0 {
+		// A --pipeline or --plan stage joins only some of the inputs.
+		if *pipelineOpt != "" || *planOpt != "" {
+			return allData
+		}
+		usagef("--prefix-match names %s, which is not an input file", *prefixMatch)
+	}
+	if len(joinColumns) != 1 || len(buckets) > 0 {
+		usagef("--prefix-match needs a single join column; set it with --on")
+	}
+
+	index := newPrefixIndex(allData[src])
+	matched := 0
+
+	out := make([]DataCollection, len(allData))
+	for i, dc := range allData {
+		if i == src {
+			out[i] = dc
+			continue
+		}
+		out[i] = NewDataCollection()
+		for k, recs := range dc.data {
+			if p, ok := index.Longest(k); ok && !strings.HasPrefix(k, separateKeyPrefix) {
+				if p != k {
+					matched++
+				}
+				k = p
+			}
+			out[i].data[k] = append(out[i].data[k], recs...)
+		}
+	}
+
+	if matched > 0 {
+		log.Printf("--prefix-match matched %d keys to a prefix from %s", matched, inputNames[src])
+	}
+
+	return out
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	prescan     = flag.Bool("prescan", false, "check the headers and first rows of every input before creating any output")
+	prescanRows = flag.Int("prescan-rows", 1000, "number of rows per input checked by --prescan")
+)
+
+// Prescan checks that every input can be parsed, is valid UTF-8 and has the
+// join columns, reading only the header and the first --prescan-rows rows.
+// Any problem fails the run before the output is touched.
+func Prescan(fileNames []string) {
+
+	if !*prescan {
+		return
+	}
+
+	inputNames = fileNames
+
+	readers := OpenReaders(fileNames)
+	allHeaders := GatherAllHeaders(readers, fileNames)
+
+	for i, header := range allHeaders {
+
+		seen := map[string]bool{}
+		for _, col := range header {
+			if seen[col] {
+				fail(&RunError{Class: errInput, File: fileNames[i], Line: 1, Message: fmt.Sprintf("%s has column %s more than once", fileNames[i], col)})
+			}
+			seen[col] = true
+		}
+
+		if len(header) == 1 {
+			for _, d := range []string{";", "\t", "|"} {
+				if strings.Contains(header[0], d) {
+					fail(&RunError{
+						Class:   errInput,
+						File:    fileNames[i],
+						Line:    1,
+						Message: fmt.Sprintf("%s has a single column %q", fileNames[i], header[0]),
+						Hint:    fmt.Sprintf("the file looks %q delimited, not comma delimited", d),
+					})
+				}
+			}
+		}
+
+		for n := 1; n <= *prescanRows; n++ {
+			offset := inputOffset(readers[i])
+			row, err := readers[i].Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				parseFailure(fileNames[i], err, n, offset)
+			}
+			for f, v := range row {
+				if !utf8.ValidString(v) {
+					line, col := fieldPos(readers[i], f)
+					fail(&RunError{
+						Class:   errInput,
+						File:    fileNames[i],
+						Line:    line,
+						Column:  col,
+						Record:  n,
+						Offset:  offset,
+						Message: fmt.Sprintf("%s record %d: column %s is not valid UTF-8", fileNames[i], n, header[f]),
+						Hint:    "convert the file to UTF-8",
+					})
+				}
+			}
+		}
+
+		releaseReader(readers, i)
+	}
+
+	ResolveBuckets(fileNames, allHeaders)
+	joinColumns := IdentifyJoinColumns(allHeaders, fileNames)
+
+	log.Printf("prescan ok: %d inputs, joining on %s", len(fileNames), strings.Join(joinColumns, ","))
+}
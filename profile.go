@@ -0,0 +1,83 @@
+package main
+
+import (
+	"flag"
+	"sort"
+	"strings"
+)
+
+var profileOpt = flag.String("profile", "", "apply a named set of options: excel-eu (semicolon delimiter, decimal comma, cp1252, byte order mark), excel (cp1252, byte order mark), tsv, or a profile defined under profiles: in the --config file. options given directly take precedence")
+
+// profiles are the built in --profile presets.
+var profiles = map[string]map[string]string{
+	"excel-eu": {"delimiter": ";", "decimal-comma": "true", "encoding": "cp1252", "strip-bom": "true"},
+	"excel":    {"encoding": "cp1252", "strip-bom": "true"},
+	"tsv":      {"delimiter": "tab"},
+}
+
+// configProfiles are the profiles defined in the --config file, if any.
+var configProfiles *YAMLMap
+
+// ApplyProfile sets the options of the --profile, leaving alone those given
+// on the command line or in the --config file options. A profile in the
+// config file replaces a built in one of the same name.
+func ApplyProfile() {
+
+	if *profileOpt == "" {
+		return
+	}
+
+	settings := map[string][]string{}
+
+	var custom *YAMLMap
+	if configProfiles != nil {
+		custom = configProfiles.Map(*profileOpt)
+	}
+
+	if p := custom; p != nil {
+		for _, name := range p.Keys {
+			values, isList := p.Get(name).([]string)
+			if !isList {
+				values = []string{p.String(name)}
+			}
+			settings[name] = values
+		}
+	} else if p, ok := profiles[*profileOpt]; ok {
+		for name, v := range p {
+			settings[name] = []string{v}
+		}
+	} else {
+		usagef("unknown --profile %s. the built in profiles are %s", *profileOpt, strings.Join(profileNames(), ", "))
+	}
+
+	set := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+
+	for name, values := range settings {
+		if set[name] {
+			continue
+		}
+		if name == "profile" || flag.Lookup(name) == nil {
+			usagef("--profile %s sets unknown option %s", *profileOpt, name)
+		}
+		for _, v := range values {
+			if err := flag.Set(name, v); err != nil {
+				usagef("--profile %s: option %s: %v", *profileOpt, name, err)
+			}
+		}
+	}
+}
+
+// profileNames lists the built in profiles.
+func profileNames() []string {
+
+	names := []string{}
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
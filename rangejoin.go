@@ -0,0 +1,224 @@
+package main
+
+import (
+	"cmp"
+	"flag"
+	"fmt"
+	"log"
+	"net/netip"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var rangeOpt = flag.String("range", "", "join two inputs on a value falling in an interval instead of on keys, as 'b:low,high=a:value': each row of a is paired with the rows of b where low <= value <= high. values may be numbers or IP addresses")
+
+var rangePattern = regexp.MustCompile(`^([^:]+):([^,]+),([^=]+)=([^:]+):(.+)$`)
+
+// rangeValue is a value compared by a range join: a number or an IP address.
+type rangeValue struct {
+	ip  netip.Addr
+	num float64
+}
+
+// parseRangeValue reads a value as a number, or failing that an IP address.
+func parseRangeValue(s string) (rangeValue, bool) {
+
+	if n, ok := parseNumber(s); ok {
+		return rangeValue{num: n}, true
+	}
+
+	ip, err := netip.ParseAddr(strings.TrimSpace(s))
+	if err != nil {
+		return rangeValue{}, false
+	}
+
+	return rangeValue{ip: ip.Unmap()}, true
+}
+
+// compare orders values: numbers by value, then IP addresses.
+func (a rangeValue) compare(b rangeValue) int {
+
+	switch {
+	case a.ip.IsValid() && b.ip.IsValid():
+		return a.ip.Compare(b.ip)
+	case a.ip.IsValid():
+		return 1
+	case b.ip.IsValid():
+		return -1
+	}
+
+	return cmp.Compare(a.num, b.num)
+}
+
+// interval is the [low, high] range of row i of the interval input.
+type interval struct {
+	low, high rangeValue
+	row       int
+}
+
+// intervalIndex finds the intervals holding a value. Intervals are sorted by
+// low bound, and maxHigh[i] is the highest high bound of intervals[:i+1], so
+// a search can stop at the first interval that nothing before could reach
+// past.
+type intervalIndex struct {
+	intervals []interval
+	maxHigh   []rangeValue
+}
+
+// newIntervalIndex indexes intervals.
+func newIntervalIndex(intervals []interval) *intervalIndex {
+
+	sort.SliceStable(intervals, func(i, j int) bool {
+		return intervals[i].low.compare(intervals[j].low) < 0
+	})
+
+	maxHigh := make([]rangeValue, len(intervals))
+	for i, iv := range intervals {
+		maxHigh[i] = iv.high
+		if i > 0 && maxHigh[i-1].compare(iv.high) > 0 {
+			maxHigh[i] = maxHigh[i-1]
+		}
+	}
+
+	return &intervalIndex{intervals: intervals, maxHigh: maxHigh}
+}
+
+// Containing returns the rows of the intervals holding v, in input order.
+func (x *intervalIndex) Containing(v rangeValue) []int {
+
+	// The intervals starting at or below v.
+	n := sort.Search(len(x.intervals), func(i int) bool {
+		return x.intervals[i].low.compare(v) > 0
+	})
+
+	rows := []int{}
+	for i := n - 1; i >= 0 && x.maxHigh[i].compare(v) >= 0; i-- {
+		if x.intervals[i].high.compare(v) >= 0 {
+			rows = append(rows, x.intervals[i].row)
+		}
+	}
+	sort.Ints(rows)
+
+	return rows
+}
+
+// rangeSides reads --range: the value input and column, and the interval
+// input and its low and high columns.
+func rangeSides(fileNames []string, allHeaders [][]string) (int, string, int, string, string) {
+
+	m := rangePattern.FindStringSubmatch(*rangeOpt)
+	if m == nil {
+		usagef("--range %s must look like 'b:low,high=a:value'", *rangeOpt)
+	}
+
+	src := func(file string, cols ...string) int {
+		i := FileIndex(fileNames, file)
+		if i < 0 {
+			usagef("--range names %s, which is not an input file", file)
+		}
+		for _, col := range cols {
+			if !contains(allHeaders[i], col) {
+				fail(&RunError{Class: errSchema, File: fileNames[i], Message: fmt.Sprintf("--range column %s not found in %s", col, fileNames[i])})
+			}
+		}
+		return i
+	}
+
+	right, left := src(m[1], m[2], m[3]), src(m[4], m[5])
+	if left == right {
+		usagef("--range needs two different inputs")
+	}
+	if len(fileNames) != 2 {
+		usagef("--range joins exactly two inputs")
+	}
+
+	return left, m[5], right, m[2], m[3]
+}
+
+// RangeJoin joins two inputs by --range, pairing each row of the value input
+// with every row of the interval input whose range holds its value. Rows with
+// no partner are output on their own, as GeoJoin does.
+func RangeJoin(readers []RowReader, fileNames []string, w RowWriter) {
+
+	inputNames = fileNames
+
+	loaded := LoadAll(readers, fileNames)
+	allHeaders := [][]string{}
+	for i, rows := range loaded {
+		if len(rows) == 0 {
+			fail(&RunError{Class: errInput, File: fileNames[i], Message: fmt.Sprintf("%s is empty", fileNames[i])})
+		}
+		allHeaders = append(allHeaders, rows[0])
+		recordInputRows(i, len(rows)-1)
+	}
+
+	left, valueCol, right, lowCol, highCol := rangeSides(fileNames, allHeaders)
+	lHeader, rHeader := allHeaders[left], allHeaders[right]
+	lRows, rRows := loaded[left][1:], loaded[right][1:]
+	value, low, high := indexOf(lHeader, valueCol), indexOf(rHeader, lowCol), indexOf(rHeader, highCol)
+
+	unread := 0
+	intervals := []interval{}
+	for i, row := range rRows {
+		lo, ok1 := parseRangeValue(row[low])
+		hi, ok2 := parseRangeValue(row[high])
+		if !ok1 || !ok2 || lo.ip.IsValid() != hi.ip.IsValid() || lo.compare(hi) > 0 {
+			unread++
+			continue
+		}
+		intervals = append(intervals, interval{low: lo, high: hi, row: i})
+	}
+	index := newIntervalIndex(intervals)
+
+	writer = w
+	rowsWritten = 0
+
+	err := writer.Write(pairHeader(lHeader, rHeader, fileNames[right]))
+	if err != nil {
+		fail(&RunError{Class: errOutput, Message: fmt.Sprintf("failed to write CSV output: %v", err)})
+	}
+
+	blankRight := make([]string, len(rHeader))
+	blankLeft := make([]string, len(lHeader))
+	matched := make([]bool, len(rRows))
+
+	emit := func(l, r []string) bool {
+		if rowLimit > 0 && rowsWritten >= rowLimit {
+			return false
+		}
+		WriteRow(append(append(newRow(len(l)+len(r)), l...), r...))
+		return true
+	}
+
+	for _, lRow := range lRows {
+
+		rows := []int{}
+		if v, ok := parseRangeValue(lRow[value]); ok {
+			rows = index.Containing(v)
+		} else {
+			unread++
+		}
+
+		for _, i := range rows {
+			matched[i] = true
+			if !emit(lRow, rRows[i]) {
+				return
+			}
+		}
+
+		if len(rows) == 0 && !emit(lRow, blankRight) {
+			return
+		}
+	}
+
+	for i, rRow := range rRows {
+		if !matched[i] && !emit(blankLeft, rRow) {
+			return
+		}
+	}
+
+	if unread > 0 {
+		log.Printf("%d rows had no readable --range value or interval and were output unmatched", unread)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestParseRangeValue(t *testing.T) {
+
+	order := []string{"-5", "0", "2.5", "10", "10.0.0.1", "10.0.0.200", "192.168.1.1", "::ffff:192.168.1.2", "2001:db8::1"}
+
+	for i := 1; i < len(order); i++ {
+		a, okA := parseRangeValue(order[i-1])
+		b, okB := parseRangeValue(order[i])
+		if !okA || !okB {
+			t.Fatalf("cannot read %q or %q", order[i-1], order[i])
+		}
+		if a.compare(b) >= 0 {
+			t.Errorf("%q does not order before %q", order[i-1], order[i])
+		}
+	}
+
+	if _, ok := parseRangeValue("not a value"); ok {
+		t.Error("read \"not a value\" as a range value")
+	}
+}
+
+func TestIntervalIndexContaining(t *testing.T) {
+
+	rnd := rand.New(rand.NewSource(1))
+
+	intervals := []interval{}
+	for row := 0; row < 300; row++ {
+		low := float64(rnd.Intn(1000))
+		high := low + float64(rnd.Intn(100))
+		if row%50 == 0 {
+			high = low + 600
+		}
+		intervals = append(intervals, interval{low: rangeValue{num: low}, high: rangeValue{num: high}, row: row})
+	}
+	brute := append([]interval{}, intervals...)
+	x := newIntervalIndex(intervals)
+
+	for v := -10.0; v <= 1710; v += 0.5 {
+		value := rangeValue{num: v}
+		want := []int{}
+		for _, iv := range brute {
+			if iv.low.compare(value) <= 0 && iv.high.compare(value) >= 0 {
+				want = append(want, iv.row)
+			}
+		}
+
+		if got := x.Containing(value); !reflect.DeepEqual(got, want) {
+			t.Fatalf("Containing(%v) = %v, want %v", v, got, want)
+		}
+	}
+}
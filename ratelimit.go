@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"strings"
+	"time"
+)
+
+var rateLimitOpts listFlag
+
+func init() {
+	flag.Var(&rateLimitOpts, "rate-limit", "limit how fast an input is read, as file=50MB/s. may be repeated")
+}
+
+// RateLimits returns the --rate-limit for each input that has one, in bytes
+// per second, indexed like fileNames.
+func RateLimits(fileNames []string) map[int]int64 {
+
+	limits := map[int]int64{}
+
+	for i, rate := range perFileOptions("--rate-limit", rateLimitOpts, fileNames) {
+		perSecond := parseByteSize("--rate-limit", strings.TrimSuffix(rate, "/s"))
+		if perSecond <= 0 {
+			usagef("--rate-limit for %s must be a rate such as 50MB/s", fileNames[i])
+		}
+		limits[i] = perSecond
+	}
+
+	return limits
+}
+
+// throttleReader reads no faster than a fixed number of bytes per second,
+// averaged from the first read.
+type throttleReader struct {
+	r         io.Reader
+	perSecond int64
+	start     time.Time
+	read      int64
+}
+
+// Throttle wraps r to read at most perSecond bytes a second.
+func Throttle(r io.Reader, perSecond int64) io.Reader {
+	return &throttleReader{r: r, perSecond: perSecond}
+}
+
+// Read reads from the underlying reader, sleeping when ahead of the allowed
+// rate. Reads are capped at a tenth of a second's worth of bytes so the rate
+// stays smooth rather than arriving in bursts.
+func (t *throttleReader) Read(p []byte) (int, error) {
+
+	if t.start.IsZero() {
+		t.start = time.Now()
+	}
+
+	if chunk := max(t.perSecond/10, 1); int64(len(p)) > chunk {
+		p = p[:chunk]
+	}
+
+	n, err := t.r.Read(p)
+	t.read += int64(n)
+
+	due := t.start.Add(time.Duration(float64(t.read) / float64(t.perSecond) * float64(time.Second)))
+	if wait := time.Until(due); wait > 0 {
+		time.Sleep(wait)
+	}
+
+	return n, err
+}
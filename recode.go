@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+var recodeOpts listFlag
+
+func init() {
+	flag.Var(&recodeOpts, "recode", "replace a column's values as they are read using a two column CSV lookup file of raw and canonical values, with a header row, as country=countries.csv. values not in the file are kept. may be repeated")
+}
+
+// recodeTables loads the --recode lookup files for the columns in headers,
+// returning each column's table of raw to canonical values.
+func recodeTables(headers []string) map[string]map[string]string {
+
+	tables := map[string]map[string]string{}
+
+	for _, spec := range recodeOpts {
+		col, path, ok := splitPair(spec, "=")
+		if !ok {
+			usagef("--recode %s must be col=lookup.csv", spec)
+		}
+		if !contains(headers, col) {
+			continue
+		}
+		if tables[col] == nil {
+			tables[col] = map[string]string{}
+		}
+		loadRecodeTable(path, tables[col])
+	}
+
+	return tables
+}
+
+// loadRecodeTable adds the rows of a lookup file, after its header, to table.
+// A raw value given two different canonical values is an error.
+func loadRecodeTable(path string, table map[string]string) {
+
+	f, err := os.Open(path)
+	if err != nil {
+		fail(&RunError{Class: errIO, File: path, Message: fmt.Sprintf("cannot read --recode file %s: %v", path, err)})
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = 2
+
+	for first := true; ; first = false {
+		row, err := r.Read()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			fail(&RunError{
+				Class:   errParse,
+				File:    path,
+				Message: fmt.Sprintf("cannot read --recode file %s: %v", path, err),
+				Hint:    "a --recode file has two columns, the raw value and its canonical value, after a header row",
+			})
+		}
+		if first {
+			continue
+		}
+		if prev, ok := table[row[0]]; ok && prev != row[1] {
+			line, _ := r.FieldPos(0)
+			fail(&RunError{
+				Class:   errSchema,
+				File:    path,
+				Line:    line,
+				Message: fmt.Sprintf("--recode file %s maps %q to both %q and %q", path, row[0], prev, row[1]),
+			})
+		}
+		table[row[0]] = row[1]
+	}
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// previewRows is how many joined rows the REPL shows by default.
+const previewRows = 20
+
+// replAbort carries a fatal error out of a REPL command so the session can
+// continue.
+type replAbort string
+
+// memReader replays rows that have already been loaded into memory.
+type memReader struct {
+	rows [][]string
+	next int
+}
+
+// Read returns the next row, or io.EOF when all rows have been returned.
+func (m *memReader) Read() ([]string, error) {
+
+	if m.next >= len(m.rows) {
+		return nil, io.EOF
+	}
+
+	row := m.rows[m.next]
+	m.next++
+
+	return row, nil
+}
+
+// LoadAll reads every row (including the header) of each input into memory.
+func LoadAll(readers []RowReader, fileNames []string) [][][]string {
+
+	all := [][][]string{}
+
+	for i, r := range readers {
+
+		rows := [][]string{}
+
+		for {
+			offset := inputOffset(r)
+			row, err := r.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				parseFailure(fileNames[i], err, len(rows), offset)
+			}
+			rows = append(rows, row)
+		}
+		releaseReader(readers, i)
+
+		all = append(all, rows)
+	}
+
+	return all
+}
+
+// Repl loads the named inputs once, then reads commands from stdin so that
+// different joins can be tried without re-reading the files.
+func Repl(args []string) {
+
+	flag.CommandLine.Parse(args)
+	LoadConfig()
+	ApplyProfile()
+	ApplyOn()
+	SetMemoryLimit()
+
+	fileNames := GetFileNames()
+	if readsStdin(fileNames) {
+		usagef("the REPL reads its commands from stdin, so no input can be read from it")
+	}
+	loaded := LoadAll(OpenReaders(fileNames), fileNames)
+
+	fmt.Printf("loaded %d files. type help for commands.\n", len(loaded))
+
+	in := bufio.NewScanner(os.Stdin)
+
+	for {
+		fmt.Print("csvjoin> ")
+
+		if !in.Scan() {
+			fmt.Println()
+			return
+		}
+
+		fields := strings.Fields(in.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		if fields[0] == "quit" || fields[0] == "exit" {
+			return
+		}
+
+		replCommand(fields, loaded, fileNames)
+	}
+}
+
+// replCommand runs a single REPL command. Fatal errors raised while running
+// are reported and the command abandoned.
+func replCommand(fields []string, loaded [][][]string, fileNames []string) {
+
+	defer func(saved func(*RunError)) {
+		fail = saved
+		if r := recover(); r != nil {
+			msg, ok := r.(replAbort)
+			if !ok {
+				panic(r)
+			}
+			fmt.Fprintf(os.Stderr, "error: %s\n", msg)
+		}
+	}(fail)
+
+	fail = func(e *RunError) {
+		panic(replAbort(e.Message))
+	}
+
+	switch fields[0] {
+
+	case "help":
+		fmt.Println("commands:")
+		fmt.Println("  files                 list inputs and their columns")
+		fmt.Println("  keys [col,...]        set the join columns (no argument: auto-detect)")
+		fmt.Println("  set <option> <value>  set a command line option")
+		fmt.Println("  unset <option>        restore an option's default")
+		fmt.Println("  show                  list the current key and option settings")
+		fmt.Println("  preview [n]           show the first n joined rows (default 20)")
+		fmt.Println("  count                 count the joined rows")
+		fmt.Println("  save <spec.yaml>      save the inputs and options for replay with --config")
+		fmt.Println("  quit                  leave the REPL")
+
+	case "files":
+		for i, rows := range loaded {
+			header := []string{}
+			if len(rows) > 0 {
+				header = rows[0]
+			}
+			fmt.Printf("%s (%d rows): %s\n", fileNames[i], len(rows)-1, strings.Join(header, ","))
+		}
+
+	case "keys":
+		keyColumns = nil
+		if len(fields) > 1 {
+			keyColumns = strings.Split(fields[1], ",")
+		}
+
+	case "set":
+		if len(fields) < 3 {
+			fatalf("usage: set <option> <value>")
+		}
+		err := flag.Set(fields[1], strings.Join(fields[2:], " "))
+		if err != nil {
+			fatalf("%v", err)
+		}
+		if fields[1] == "on" || fields[1] == "not-on" {
+			ApplyOn()
+		}
+
+	case "unset":
+		if len(fields) < 2 {
+			fatalf("usage: unset <option>")
+		}
+		f := flag.Lookup(fields[1])
+		if f == nil {
+			fatalf("no such option: %s", fields[1])
+		}
+		if r, ok := f.Value.(interface{ Reset() }); ok {
+			r.Reset()
+		} else {
+			f.Value.Set(f.DefValue)
+		}
+		if f.Name == "on" || f.Name == "not-on" {
+			ApplyOn()
+		}
+
+	case "show":
+		if len(keyColumns) > 0 {
+			fmt.Printf("keys: %s\n", strings.Join(keyColumns, ","))
+		} else {
+			fmt.Println("keys: (auto-detect)")
+		}
+		flag.VisitAll(func(f *flag.Flag) {
+			if f.Value.String() != f.DefValue {
+				fmt.Printf("%s: %s\n", f.Name, f.Value.String())
+			}
+		})
+
+	case "preview":
+		n := previewRows
+		if len(fields) > 1 {
+			var err error
+			n, err = strconv.Atoi(fields[1])
+			if err != nil || n <= 0 {
+				fatalf("preview needs a positive row count")
+			}
+		}
+		out := csv.NewWriter(os.Stdout)
+		ow := NewOutputWriter(out)
+		replJoin(loaded, fileNames, ow, n)
+		ow.Flush()
+		out.Flush()
+
+	case "count":
+		counter := &countingWriter{}
+		replJoin(loaded, fileNames, counter, 0)
+		fmt.Printf("%d rows\n", counter.rows-1)
+
+	case "save":
+		if len(fields) < 2 {
+			fatalf("usage: save <spec.yaml>")
+		}
+		WriteSpec(fields[1], fileNames)
+
+	default:
+		fatalf("unknown command %s. type help for commands.", fields[0])
+	}
+}
+
+// replJoin runs a join over the loaded data, stopping after limit rows if
+// limit is positive.
+func replJoin(loaded [][][]string, fileNames []string, w RowWriter, limit int) {
+
+	readers := []RowReader{}
+	for _, rows := range loaded {
+		readers = append(readers, &memReader{rows: rows})
+	}
+
+	defer func(saved int) {
+		rowLimit = saved
+	}(rowLimit)
+	rowLimit = limit
+
+	Run(readers, fileNames, w)
+}
+
+// countingWriter counts the rows written to it and discards them.
+type countingWriter struct {
+	rows int
+}
+
+// Write counts the row.
+func (c *countingWriter) Write(row []string) error {
+	c.rows++
+	return nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"flag"
+	"reflect"
+	"testing"
+)
+
+// scratchFlags points flag.CommandLine at a copy of itself for the rest of
+// the test, so that the options it sets do not count as given on the command
+// line once it is over.
+func scratchFlags(t *testing.T) {
+
+	saved := flag.CommandLine
+	scratch := flag.NewFlagSet(saved.Name(), flag.ContinueOnError)
+	saved.VisitAll(func(f *flag.Flag) {
+		scratch.Var(f.Value, f.Name, f.Usage)
+	})
+
+	flag.CommandLine = scratch
+	t.Cleanup(func() { flag.CommandLine = saved })
+}
+
+func TestReplSetOn(t *testing.T) {
+
+	scratchFlags(t)
+
+	defer func(old string) {
+		*on = old
+		ApplyOn()
+	}(*on)
+
+	replCommand([]string{"set", "on", "id,region"}, nil, nil)
+	if want := []string{"id", "region"}; !reflect.DeepEqual(keyColumns, want) {
+		t.Errorf("after set on, keyColumns = %v, want %v", keyColumns, want)
+	}
+
+	replCommand([]string{"unset", "on"}, nil, nil)
+	if keyColumns != nil {
+		t.Errorf("after unset on, keyColumns = %v, want auto-detection", keyColumns)
+	}
+}
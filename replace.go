@@ -0,0 +1,195 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	replaceOpts listFlag
+	replaceAt   = flag.String("replace-at", "read", "when --replace substitutions apply: read (before keys are matched) or write (to the output only)")
+)
+
+func init() {
+	flag.Var(&replaceOpts, "replace", "sed style substitution on a column, as 'phone:s/[^0-9]//g'. flags g and i are supported. may be repeated")
+}
+
+// Substitution is a compiled sed style s/pattern/replacement/flags command.
+type Substitution struct {
+	re          *regexp.Regexp
+	replacement string
+	global      bool
+}
+
+// ParseSubstitution compiles a sed style substitution. Any character may
+// follow the s as the delimiter, and a backslash before it makes it literal.
+// In the replacement, & is the whole match and \1 to \9 the groups.
+func ParseSubstitution(src string) (*Substitution, error) {
+
+	rs := []rune(src)
+	if len(rs) < 2 || rs[0] != 's' {
+		return nil, fmt.Errorf("%q is not a substitution such as s/old/new/g", src)
+	}
+
+	delim := rs[1]
+	parts := []string{}
+	part := []rune{}
+
+	for i := 2; i < len(rs); i++ {
+		switch {
+		case rs[i] == '\\' && i+1 < len(rs) && rs[i+1] == delim && len(parts) == 0:
+			// Literal in the pattern even when the delimiter means something
+			// to regexp, as | or . do.
+			part = append(part, []rune(regexp.QuoteMeta(string(delim)))...)
+			i++
+		case rs[i] == '\\' && i+1 < len(rs) && rs[i+1] == delim:
+			// Left escaped for sedReplacement, so that a delimiter such as &
+			// stays literal.
+			part = append(part, rs[i], delim)
+			i++
+		case rs[i] == '\\' && i+1 < len(rs):
+			part = append(part, rs[i], rs[i+1])
+			i++
+		case rs[i] == delim && len(parts) < 2:
+			parts = append(parts, string(part))
+			part = []rune{}
+		default:
+			part = append(part, rs[i])
+		}
+	}
+
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("substitution %q is not terminated", src)
+	}
+
+	s := &Substitution{replacement: sedReplacement(parts[1])}
+	pattern := parts[0]
+
+	for _, f := range string(part) {
+		switch f {
+		case 'g':
+			s.global = true
+		case 'i':
+			pattern = "(?i)" + pattern
+		default:
+			return nil, fmt.Errorf("substitution %q has unknown flag %c", src, f)
+		}
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("substitution %q: %v", src, err)
+	}
+	s.re = re
+
+	return s, nil
+}
+
+// sedReplacement rewrites a sed replacement in the template syntax of
+// regexp.Expand.
+func sedReplacement(r string) string {
+
+	sb := strings.Builder{}
+	rs := []rune(r)
+
+	for i := 0; i < len(rs); i++ {
+		switch c := rs[i]; {
+		case c == '\\' && i+1 < len(rs):
+			i++
+			switch n := rs[i]; {
+			case n >= '0' && n <= '9':
+				sb.WriteString("${" + string(n) + "}")
+			case n == 'n':
+				sb.WriteByte('\n')
+			case n == 't':
+				sb.WriteByte('\t')
+			case n == '$':
+				sb.WriteString("$$")
+			default:
+				sb.WriteRune(n)
+			}
+		case c == '&':
+			sb.WriteString("${0}")
+		case c == '$':
+			sb.WriteString("$$")
+		default:
+			sb.WriteRune(c)
+		}
+	}
+
+	return sb.String()
+}
+
+// Apply makes the substitution in v: every match with the g flag, otherwise
+// the first.
+func (s *Substitution) Apply(v string) string {
+
+	if s.global {
+		return s.re.ReplaceAllString(v, s.replacement)
+	}
+
+	m := s.re.FindStringSubmatchIndex(v)
+	if m == nil {
+		return v
+	}
+
+	out := s.re.ExpandString(nil, s.replacement, v, m)
+
+	return v[:m[0]] + string(out) + v[m[1]:]
+}
+
+// columnSubstitutions parses --replace, returning each column's
+// substitutions in the order given.
+func columnSubstitutions() map[string][]*Substitution {
+
+	switch *replaceAt {
+	case "read", "write":
+	default:
+		usagef("--replace-at %s must be read or write", *replaceAt)
+	}
+
+	subs := map[string][]*Substitution{}
+
+	for _, spec := range replaceOpts {
+		col, expr, ok := splitPair(spec, ":")
+		if !ok {
+			usagef("--replace %s must be col:s/old/new/flags", spec)
+		}
+		s, err := ParseSubstitution(expr)
+		if err != nil {
+			usagef("--replace for %s: %v", col, err)
+		}
+		subs[col] = append(subs[col], s)
+	}
+
+	return subs
+}
+
+// RowReplacer builds the function applying --replace to output rows with the
+// given columns when --replace-at is write, or returns nil.
+func RowReplacer(outputColumns []string) func(row []string) {
+
+	subs := columnSubstitutions()
+	if *replaceAt != "write" || len(subs) == 0 {
+		return nil
+	}
+
+	byIndex := map[int][]*Substitution{}
+	for col, s := range subs {
+		i := indexOf(outputColumns, col)
+		if i < 0 {
+			usagef("--replace column %s is not an output column", col)
+		}
+		byIndex[i] = s
+	}
+
+	return func(row []string) {
+		for i, subs := range byIndex {
+			for _, s := range subs {
+				row[i] = s.Apply(row[i])
+			}
+		}
+	}
+}
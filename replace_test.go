@@ -0,0 +1,33 @@
+package main
+
+import "testing"
+
+func TestParseSubstitution(t *testing.T) {
+
+	tests := []struct {
+		sub  string
+		in   string
+		want string
+	}{
+		{"s/a/b/", "aa", "ba"},
+		{"s/a/b/g", "aa", "bb"},
+		{"s/A/b/gi", "aA", "bb"},
+		{`s/\//-/g`, "a/b/c", "a-b-c"},
+		{`s|a\|b|x|g`, "a|b ab b", "x ab b"},
+		{`s.a\.b.x.`, "axb a.b", "axb x"},
+		{`s&a&\&&`, "ab", "&b"},
+		{`s/(\w+)@(\w+)/\2 at \1/`, "me@home", "home at me"},
+		{"s/o/[&]/g", "foo", "f[o][o]"},
+	}
+
+	for _, tt := range tests {
+		s, err := ParseSubstitution(tt.sub)
+		if err != nil {
+			t.Errorf("%s: %v", tt.sub, err)
+			continue
+		}
+		if got := s.Apply(tt.in); got != tt.want {
+			t.Errorf("%s on %q = %q, want %q", tt.sub, tt.in, got, tt.want)
+		}
+	}
+}
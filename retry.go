@@ -0,0 +1,104 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"log"
+	"os"
+	"time"
+)
+
+var (
+	readRetries = flag.Int("read-retries", 0, "retry a failed read this many times, reopening the input and carrying on from the last good byte. for flaky network mounts")
+	retryDelay  = flag.Duration("retry-delay", time.Second, "wait before the first --read-retries attempt, doubling for each further attempt")
+)
+
+// retryReader reads a file, reopening it and seeking back to the last byte
+// read successfully when a read fails.
+type retryReader struct {
+	name   string
+	path   string
+	f      *os.File
+	offset int64
+	failed int
+}
+
+// OpenInput opens an input file, wrapped to retry failed reads if
+// --read-retries is set, or stdin for an input given as -.
+func OpenInput(fName string) (io.ReadCloser, error) {
+
+	path := inputPath(fName)
+
+	// Stdin can't be reopened to retry a read.
+	if path == stdinName {
+		return io.NopCloser(os.Stdin), nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if *readRetries <= 0 {
+		return f, nil
+	}
+
+	return &retryReader{name: fName, path: path, f: f}, nil
+}
+
+// Read reads from the file. A failed read is retried up to --read-retries
+// times in a row; a successful read resets the count.
+func (r *retryReader) Read(p []byte) (int, error) {
+
+	for {
+		n, err := r.f.Read(p)
+		r.offset += int64(n)
+
+		if err == nil || err == io.EOF || r.failed >= *readRetries {
+			if n > 0 {
+				r.failed = 0
+			}
+			return n, err
+		}
+
+		if n > 0 {
+			// Hand over what was read; the error will come up again on the
+			// next read if it persists.
+			r.failed = 0
+			return n, nil
+		}
+
+		r.failed++
+		wait := *retryDelay << (r.failed - 1)
+		log.Printf("%s: read failed at byte %d: %v. retrying in %v (%d of %d)", r.name, r.offset, err, wait, r.failed, *readRetries)
+		time.Sleep(wait)
+
+		r.reopen()
+	}
+}
+
+// reopen replaces the file handle with a new one positioned at the last good
+// offset. If that fails the old handle is kept, for the next attempt to find
+// the problem again.
+func (r *retryReader) reopen() {
+
+	f, err := os.Open(r.path)
+	if err == nil {
+		_, err = f.Seek(r.offset, io.SeekStart)
+		if err != nil {
+			f.Close()
+		}
+	}
+	if err != nil {
+		log.Printf("%s: cannot reopen: %v", r.name, err)
+		return
+	}
+
+	r.f.Close()
+	r.f = f
+}
+
+// Close closes the current file handle.
+func (r *retryReader) Close() error {
+	return r.f.Close()
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// peakRSS is not known on this system.
+func peakRSS(ps *os.ProcessState) (int64, bool) {
+	return 0, false
+}
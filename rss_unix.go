@@ -0,0 +1,26 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"runtime"
+	"syscall"
+)
+
+// peakRSS returns the most memory a finished process had resident, in
+// bytes.
+func peakRSS(ps *os.ProcessState) (int64, bool) {
+
+	ru, ok := ps.SysUsage().(*syscall.Rusage)
+	if !ok {
+		return 0, false
+	}
+
+	// Linux and most other systems report kilobytes, macOS bytes.
+	if runtime.GOOS == "darwin" || runtime.GOOS == "ios" {
+		return int64(ru.Maxrss), true
+	}
+
+	return int64(ru.Maxrss) * 1024, true
+}
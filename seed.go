@@ -0,0 +1,23 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"math/rand"
+	"time"
+)
+
+var seed = flag.Int64("seed", 1, "seed for random choices, such as the rows --stratify-sample keeps. runs with the same seed and inputs make the same choices. 0 picks a seed from the clock and logs it")
+
+// seededRand returns a random source seeded with seed, which option set. A
+// seed of 0 is replaced with one from the clock, logged so that the run can
+// be repeated.
+func seededRand(option string, seed int64) *rand.Rand {
+
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+		log.Printf("%s 0: using seed %d. give --seed %d to repeat this run", option, seed, seed)
+	}
+
+	return rand.New(rand.NewSource(seed))
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var snapshotInputs = flag.Bool("snapshot-inputs", false, "copy each input to a temporary directory before reading it, so a file rewritten by its producer during the run can't change mid-read. the copies are removed afterwards")
+
+var (
+	// snapshotDir holds the --snapshot-inputs copies, if any.
+	snapshotDir string
+
+	// snapshots maps an input's name to the path of its copy.
+	snapshots = map[string]string{}
+)
+
+// SnapshotInputs copies the inputs for --snapshot-inputs. Each copy keeps
+// its original's modification time. The inputs are copied rather than hard
+// linked, as a link would still see a producer rewriting the file in place.
+// An input read from stdin is copied too when it is to be read more than
+// once, as by --prescan.
+func SnapshotInputs(fileNames []string) {
+
+	spool := readsStdin(fileNames) && stdinSpooled()
+	if !*snapshotInputs && !spool {
+		return
+	}
+
+	dir, err := os.MkdirTemp("", "csvjoin-snapshot-")
+	if err != nil {
+		fail(&RunError{Class: errIO, Message: fmt.Sprintf("cannot create a directory for --snapshot-inputs: %v", err)})
+	}
+	snapshotDir = dir
+
+	for i, fName := range fileNames {
+
+		if !*snapshotInputs && !isStdin(fName) {
+			continue
+		}
+
+		src, _ := splitAlias(fName)
+		path := filepath.Join(dir, fmt.Sprintf("%d-%s", i, filepath.Base(src)))
+
+		modTime, err := copyFile(src, path)
+		if err != nil {
+			fail(&RunError{Class: errIO, File: fName, Message: fmt.Sprintf("cannot snapshot %s: %v", fName, err)})
+		}
+		os.Chtimes(path, modTime, modTime)
+
+		snapshots[fName] = path
+	}
+
+	if *snapshotInputs {
+		log.Printf("read %d inputs from snapshots in %s", len(fileNames), dir)
+	}
+}
+
+// copyFile copies src, or stdin if src is stdinName, to dst, returning
+// src's modification time. Stdin's is the time it was copied.
+func copyFile(src, dst string) (time.Time, error) {
+
+	in, modTime := os.Stdin, time.Now()
+	if src != stdinName {
+		f, err := os.Open(src)
+		if err != nil {
+			return time.Time{}, err
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil {
+			return time.Time{}, err
+		}
+		in, modTime = f, info.ModTime()
+	}
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	_, err = io.Copy(out, in)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+
+	return modTime, err
+}
+
+// inputPath returns the path to read an input from: its snapshot, if it has
+// one, otherwise its name without any alias.
+func inputPath(fName string) string {
+
+	if path, ok := snapshots[fName]; ok {
+		return path
+	}
+
+	path, _ := splitAlias(fName)
+
+	return path
+}
+
+// RemoveSnapshots removes the --snapshot-inputs copies.
+func RemoveSnapshots() {
+
+	if snapshotDir == "" {
+		return
+	}
+
+	os.RemoveAll(snapshotDir)
+	snapshotDir = ""
+	snapshots = map[string]string{}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+var (
+	configFile = flag.String("config", "", "read inputs and options from a spec file written by --save-spec. command line options take precedence")
+	saveSpec   = flag.String("save-spec", "", "write the inputs and resolved options of this run to a spec file that --config can replay")
+
+	// configFiles are the inputs named by the --config file.
+	configFiles []string
+
+	// resolvedJoinColumns are the join columns the last join used.
+	resolvedJoinColumns []string
+)
+
+// specOnlyFlags are not written to spec files.
+var specOnlyFlags = map[string]bool{"config": true, "save-spec": true}
+
+// keyChoiceFlags choose the join columns. Once the columns are resolved, the
+// spec names them with on in place of these.
+var keyChoiceFlags = map[string]bool{"not-on": true, "auto-key": true, "pick-key": true}
+
+// LoadConfig applies the --config file. Options already given on the command
+// line are left alone.
+func LoadConfig() {
+
+	if *configFile == "" {
+		return
+	}
+
+	data, err := os.ReadFile(*configFile)
+	if err != nil {
+		fail(&RunError{Class: errIO, File: *configFile, Message: fmt.Sprintf("cannot read config file: %v", err)})
+	}
+
+	doc, err := ParseYAML(string(data))
+	if err != nil {
+		fail(&RunError{Class: errUsage, File: *configFile, Message: fmt.Sprintf("cannot parse config file %s: %v", *configFile, err)})
+	}
+
+	if files, ok := doc.Get("files").([]string); ok {
+		configFiles = files
+	}
+	configProfiles = doc.Map("profiles")
+
+	options := doc.Map("options")
+	if options == nil {
+		return
+	}
+
+	onCommandLine := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) {
+		onCommandLine[f.Name] = true
+	})
+
+	for _, name := range options.Keys {
+
+		if onCommandLine[name] || specOnlyFlags[name] {
+			continue
+		}
+		if flag.Lookup(name) == nil {
+			usagef("config file %s sets unknown option %s", *configFile, name)
+		}
+
+		values, isList := options.Get(name).([]string)
+		if !isList {
+			values = []string{options.String(name)}
+		}
+
+		for _, v := range values {
+			if err := flag.Set(name, v); err != nil {
+				usagef("config file %s: option %s: %v", *configFile, name, err)
+			}
+		}
+	}
+}
+
+// WriteSpec writes a spec file describing the inputs and every option that
+// differs from its default. The join columns the last join resolved are
+// written as on, so that replaying the spec joins on the same columns
+// without detecting or asking for them again.
+func WriteSpec(name string, fileNames []string) {
+
+	sb := strings.Builder{}
+
+	fmt.Fprintf(&sb, "# csvjoin spec written %s\n", time.Now().UTC().Format(time.RFC3339))
+
+	sb.WriteString("files:\n")
+	for _, f := range fileNames {
+		fmt.Fprintf(&sb, "  - %s\n", yamlQuote(f))
+	}
+
+	options := []string{}
+	flag.VisitAll(func(f *flag.Flag) {
+		if len(resolvedJoinColumns) > 0 {
+			switch {
+			case f.Name == "on":
+				options = append(options, fmt.Sprintf("  on: %s", yamlQuote(strings.Join(resolvedJoinColumns, ","))))
+				return
+			case keyChoiceFlags[f.Name]:
+				return
+			}
+		}
+		if specOnlyFlags[f.Name] || f.Value.String() == f.DefValue {
+			return
+		}
+		if list, ok := f.Value.(*listFlag); ok {
+			items := []string{}
+			for _, v := range *list {
+				items = append(items, "    - "+yamlQuote(v))
+			}
+			options = append(options, fmt.Sprintf("  %s:\n%s", f.Name, strings.Join(items, "\n")))
+			return
+		}
+		options = append(options, fmt.Sprintf("  %s: %s", f.Name, yamlQuote(f.Value.String())))
+	})
+
+	if len(options) > 0 {
+		sb.WriteString("options:\n")
+		sb.WriteString(strings.Join(options, "\n"))
+		sb.WriteString("\n")
+	}
+
+	err := os.WriteFile(name, []byte(sb.String()), 0644)
+	if err != nil {
+		fail(&RunError{Class: errIO, File: name, Message: fmt.Sprintf("cannot write spec file: %v", err)})
+	}
+}
+
+// yamlQuote quotes s if it would not otherwise read back as the same scalar.
+func yamlQuote(s string) string {
+
+	if s != "" && !strings.ContainsAny(s, ":#[]'\",|") && strings.TrimSpace(s) == s && !strings.HasPrefix(s, "-") {
+		return s
+	}
+
+	if strings.Contains(s, "\"") {
+		return "'" + s + "'"
+	}
+
+	return "\"" + s + "\""
+}
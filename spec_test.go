@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSavedSpecReplaysResolvedKey(t *testing.T) {
+
+	scratchFlags(t)
+
+	defer func(oldOn string, oldPick bool, oldConfig string, oldResolved []string) {
+		*on, *pickKey, *configFile, resolvedJoinColumns = oldOn, oldPick, oldConfig, oldResolved
+		keyColumns = nil
+	}(*on, *pickKey, *configFile, resolvedJoinColumns)
+
+	left := "id,region,left\n1,n,l1\n2,s,l2\n"
+	right := "id,region,right\n1,s,r1\n2,s,r2\n"
+
+	// As if --pick-key had been answered with id.
+	*pickKey = true
+	resolvedJoinColumns = []string{"id"}
+
+	spec := filepath.Join(t.TempDir(), "spec.yaml")
+	WriteSpec(spec, []string{"a.csv", "b.csv"})
+
+	data, err := os.ReadFile(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "pick-key") {
+		t.Errorf("saved spec still has pick-key:\n%s", data)
+	}
+
+	*pickKey = false
+	*configFile = spec
+	LoadConfig()
+	ApplyOn()
+
+	if *pickKey {
+		t.Fatal("replayed spec turns --pick-key on")
+	}
+	if !reflect.DeepEqual(keyColumns, []string{"id"}) {
+		t.Fatalf("replayed spec joins on %v, want [id]", keyColumns)
+	}
+
+	rows := joinStrings(t, left, right)
+	if len(rows) != 3 {
+		t.Errorf("got %d rows, want a header and 2 joined on id: %q", len(rows), rows)
+	}
+}
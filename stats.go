@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"log"
+	"net/http"
+	"time"
+)
+
+var notifyWebhook = flag.String("notify-webhook", "", "POST a JSON run summary to this URL when the run finishes or fails")
+
+// InputStats summarises one input.
+type InputStats struct {
+	File string `json:"file"`
+	Rows int    `json:"rows"`
+}
+
+// OutputStats summarises one output file.
+type OutputStats struct {
+	Path  string `json:"path"`
+	Rows  int    `json:"rows"`
+	Bytes int64  `json:"bytes"`
+}
+
+// RunSummary describes a finished or failed run.
+type RunSummary struct {
+	Status  string        `json:"status"`
+	Started string        `json:"started"`
+	Seconds float64       `json:"seconds"`
+	Inputs  []InputStats  `json:"inputs"`
+	Keys    int           `json:"keys"`
+	Outputs []OutputStats `json:"outputs"`
+	Skipped int           `json:"skipped_keys,omitempty"`
+	Dropped []*DropStats  `json:"dropped,omitempty"`
+	Error   *RunError     `json:"error,omitempty"`
+}
+
+var (
+	runStarted = time.Now()
+	runInputs  = []InputStats{}
+	runKeys    int
+)
+
+// recordInputRows notes how many rows input src had.
+func recordInputRows(src int, rows int) {
+
+	for len(runInputs) < len(inputNames) {
+		runInputs = append(runInputs, InputStats{File: inputNames[len(runInputs)]})
+	}
+
+	runInputs[src].Rows = rows
+}
+
+// Summary describes the run so far. e is the error that ended it, if any.
+func Summary(e *RunError) RunSummary {
+
+	s := RunSummary{
+		Status:  "ok",
+		Started: runStarted.UTC().Format(time.RFC3339),
+		Seconds: time.Since(runStarted).Seconds(),
+		Inputs:  runInputs,
+		Keys:    runKeys,
+		Outputs: []OutputStats{},
+		Skipped: skippedKeys,
+		Dropped: drops,
+		Error:   e,
+	}
+
+	if e != nil {
+		s.Status = "failed"
+	}
+
+	for _, o := range outputs {
+		s.Outputs = append(s.Outputs, OutputStats{Path: o.Name, Rows: o.Rows, Bytes: o.Bytes})
+	}
+
+	return s
+}
+
+// NotifyWebhook posts the run summary to --notify-webhook. A failed
+// notification is logged but does not fail the run.
+func NotifyWebhook(e *RunError) {
+
+	if *notifyWebhook == "" {
+		return
+	}
+
+	body, err := json.Marshal(Summary(e))
+	if err != nil {
+		log.Printf("cannot encode run summary: %v", err)
+		return
+	}
+
+	client := http.Client{Timeout: 30 * time.Second}
+
+	resp, err := client.Post(*notifyWebhook, "application/json", bytes.NewReader(body))
+	if err != nil {
+		log.Printf("webhook notification failed: %v", err)
+		return
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		log.Printf("webhook notification failed: %s", resp.Status)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+// stdinName is the name an input read from stdin goes by, given as - on the
+// command line.
+const stdinName = "stdin"
+
+// StdinArgs renames an input given as -, or -:alias, to stdinName, and a
+// file really named stdin to ./stdin so that the two can't be confused. Only
+// one input can be read from stdin.
+func StdinArgs(fileNames []string) []string {
+
+	names := make([]string, len(fileNames))
+	fromStdin := 0
+
+	for i, name := range fileNames {
+		path, alias := splitAlias(name)
+		switch path {
+		case "-":
+			path = stdinName
+			fromStdin++
+		case stdinName:
+			path = "./" + stdinName
+		}
+		if alias != "" {
+			path += ":" + alias
+		}
+		names[i] = path
+	}
+
+	if fromStdin > 1 {
+		usagef("only one input can be read from stdin (-), but %d were given", fromStdin)
+	}
+
+	return names
+}
+
+// isStdin reports whether an input is read from stdin.
+func isStdin(fName string) bool {
+
+	path, _ := splitAlias(fName)
+
+	return path == stdinName
+}
+
+// readsStdin reports whether any of the inputs is read from stdin.
+func readsStdin(fileNames []string) bool {
+
+	for _, fName := range fileNames {
+		if isStdin(fName) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// stdinSpooled reports whether stdin must be copied to a file before it is
+// joined, because it is to be read more than once.
+func stdinSpooled() bool {
+	return *prescan || len(verifyOpts) > 0
+}
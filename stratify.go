@@ -0,0 +1,157 @@
+package main
+
+import (
+	"flag"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+)
+
+var (
+	stratifyOpt    = flag.String("stratify", "", "tag output rows with a numeric range bucket, as col=0-100,100-1000,1000+. adds a <col>_bucket column")
+	stratifySample = flag.Int("stratify-sample", 0, "with --stratify, keep a random sample of at most this many rows per bucket")
+)
+
+// stratum is one --stratify range: low <= value < high.
+type stratum struct {
+	label     string
+	low, high float64
+}
+
+// Stratifier labels output rows with their --stratify bucket, and optionally
+// samples a fixed number of rows from each bucket.
+type Stratifier struct {
+	column int
+	strata []stratum
+	sample int
+	rnd    *rand.Rand
+
+	// With sampling, held[b] is bucket b's reservoir and seen[b] the number
+	// of rows offered to it. The last bucket is for rows outside every range.
+	held [][]heldRow
+	seen []int
+	next int
+}
+
+type heldRow struct {
+	seq int
+	row []string
+}
+
+// NewStratifier parses --stratify against the output header, returning nil
+// if it is not set.
+func NewStratifier(header []string) *Stratifier {
+
+	if *stratifyOpt == "" {
+		return nil
+	}
+
+	col, ranges, ok := splitPair(*stratifyOpt, "=")
+	if !ok {
+		usagef("--stratify %s must be col=range,range,...", *stratifyOpt)
+	}
+
+	s := &Stratifier{column: indexOf(header, col), sample: *stratifySample}
+	if s.column < 0 {
+		usagef("--stratify column %s is not an output column", col)
+	}
+
+	for _, r := range strings.Split(ranges, ",") {
+		st, ok := parseStratum(strings.TrimSpace(r))
+		if !ok {
+			usagef("--stratify range %s must look like 0-100 or 1000+", r)
+		}
+		s.strata = append(s.strata, st)
+	}
+
+	if s.sample > 0 {
+		s.rnd = seededRand("--seed", *seed)
+		s.held = make([][]heldRow, len(s.strata)+1)
+		s.seen = make([]int, len(s.strata)+1)
+	}
+
+	return s
+}
+
+// parseStratum reads low-high or low+.
+func parseStratum(r string) (stratum, bool) {
+
+	if strings.HasSuffix(r, "+") {
+		low, ok := parseNumber(strings.TrimSuffix(r, "+"))
+		return stratum{label: r, low: low, high: math.Inf(1)}, ok
+	}
+
+	// Skip the first character so a negative low bound isn't taken as the
+	// separator.
+	dash := strings.Index(r[min(1, len(r)):], "-") + 1
+	if dash <= 0 {
+		return stratum{}, false
+	}
+
+	low, ok1 := parseNumber(r[:dash])
+	high, ok2 := parseNumber(r[dash+1:])
+
+	return stratum{label: r, low: low, high: high}, ok1 && ok2 && low < high
+}
+
+// Bucket returns the index of the row's bucket, len(strata) if it is in none.
+func (s *Stratifier) Bucket(row []string) int {
+
+	v, ok := parseNumber(row[s.column])
+	if ok {
+		for i, st := range s.strata {
+			if v >= st.low && v < st.high {
+				return i
+			}
+		}
+	}
+
+	return len(s.strata)
+}
+
+// Label returns the name of a bucket.
+func (s *Stratifier) Label(bucket int) string {
+
+	if bucket == len(s.strata) {
+		return ""
+	}
+
+	return s.strata[bucket].label
+}
+
+// Offer adds a row to its bucket's sample, reservoir style, so that every row
+// in the bucket is equally likely to be kept.
+func (s *Stratifier) Offer(bucket int, row []string) {
+
+	s.seen[bucket]++
+	s.next++
+	h := heldRow{seq: s.next, row: append([]string{}, row...)}
+
+	if len(s.held[bucket]) < s.sample {
+		s.held[bucket] = append(s.held[bucket], h)
+		return
+	}
+
+	if j := s.rnd.Intn(s.seen[bucket]); j < s.sample {
+		s.held[bucket][j] = h
+	}
+}
+
+// Sampled returns the sampled rows, bucket by bucket, each bucket's rows in
+// the order they arrived.
+func (s *Stratifier) Sampled() [][]string {
+
+	rows := [][]string{}
+
+	for _, held := range s.held {
+		sort.Slice(held, func(i, j int) bool {
+			return held[i].seq < held[j].seq
+		})
+		for _, h := range held {
+			rows = append(rows, h.row)
+		}
+	}
+
+	return rows
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var (
+	recordTerminator       = flag.String("record-terminator", "lf", "what ends each input record: lf (which also reads crlf), nul, rs (\\x1e) or another single character, such as \\x1f. with nul or rs, values may hold raw newlines")
+	outputRecordTerminator = flag.String("output-record-terminator", "", "what ends each output record, as for --record-terminator. by default the --record-terminator, if not lf, or else lf or crlf as --crlf says")
+)
+
+// terminatorByte reads a record terminator option, returning the byte that
+// ends records, or false for the usual line endings.
+func terminatorByte(option, name string) (byte, bool) {
+
+	switch name {
+	case "lf", "crlf", "":
+		return 0, false
+	case "nul":
+		return 0x00, true
+	case "rs":
+		return 0x1e, true
+	}
+
+	s, err := strconv.Unquote(`"` + name + `"`)
+	if err != nil || len(s) != 1 || s[0] == '"' || s[0] == '\r' || s[0] == '\n' {
+		usagef("%s %s must be lf, crlf, nul, rs or a single character such as \\x1f", option, name)
+	}
+
+	return s[0], true
+}
+
+// InputTerminator returns the --record-terminator byte, or false if
+// records end in newlines.
+func InputTerminator() (byte, bool) {
+	return terminatorByte("--record-terminator", *recordTerminator)
+}
+
+// OutputTerminator returns the byte to end output records with, or false
+// for the usual line endings.
+func OutputTerminator() (byte, bool) {
+
+	if *outputRecordTerminator != "" {
+		return terminatorByte("--output-record-terminator", *outputRecordTerminator)
+	}
+
+	return InputTerminator()
+}
+
+// exchange swaps each terminator byte in p for a newline and each newline
+// for the terminator, in place. It keeps every byte at its offset, and done
+// twice gives back what it started with.
+func exchange(p []byte, term byte) {
+
+	for i, c := range p {
+		switch c {
+		case term:
+			p[i] = '\n'
+		case '\n':
+			p[i] = term
+		}
+	}
+}
+
+// terminatedReader reads input whose records end in a terminator as though
+// they ended in newlines. The terminator and newlines are exchanged
+// throughout, quoted or not, so that restoreNewlines can exchange them back
+// in each parsed value, and a terminator byte quoted in a value comes out as
+// itself.
+type terminatedReader struct {
+	r    io.Reader
+	term byte
+}
+
+// Read reads and converts the next bytes.
+func (t *terminatedReader) Read(p []byte) (int, error) {
+
+	n, err := t.r.Read(p)
+	exchange(p[:n], t.term)
+
+	return n, err
+}
+
+// TerminatedReader returns r converted for the CSV reader, if a
+// --record-terminator is set.
+func TerminatedReader(r io.Reader) io.Reader {
+
+	term, ok := InputTerminator()
+	if !ok {
+		return r
+	}
+
+	return &terminatedReader{r: r, term: term}
+}
+
+// restoreNewlines exchanges the terminator bytes and newlines in a parsed
+// record back, undoing terminatedReader.
+func restoreNewlines(row []string, term byte) {
+
+	for i, v := range row {
+		if strings.IndexByte(v, term) >= 0 || strings.IndexByte(v, '\n') >= 0 {
+			b := []byte(v)
+			exchange(b, term)
+			row[i] = string(b)
+		}
+	}
+}
+
+// terminatedWriter ends the records the CSV writer writes with the
+// terminator. The CSV writer quotes values holding newlines, so they are
+// left alone, as are terminator bytes in quoted values. It does not quote
+// values for holding the terminator, though, and such a value would end its
+// record early, so it is refused.
+type terminatedWriter struct {
+	w      io.Writer
+	term   byte
+	quoted bool
+	buf    []byte
+}
+
+// Write converts the bytes and writes them on.
+func (t *terminatedWriter) Write(p []byte) (int, error) {
+
+	t.buf = append(t.buf[:0], p...)
+
+	for i, c := range t.buf {
+		switch {
+		case c == '"':
+			t.quoted = !t.quoted
+		case t.quoted:
+		case c == '\n':
+			t.buf[i] = t.term
+		case c == t.term:
+			return 0, fmt.Errorf("a value holds the output record terminator %q, which csv output cannot quote", t.term)
+		}
+	}
+
+	return t.w.Write(t.buf)
+}
+
+// TerminatedWriter returns w converted to end records with the output record
+// terminator, if one is set.
+func TerminatedWriter(w io.Writer) io.Writer {
+
+	term, ok := OutputTerminator()
+	if !ok {
+		return w
+	}
+
+	return &terminatedWriter{w: w, term: term}
+}
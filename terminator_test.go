@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// readTerminated parses input ending its records in --record-terminator.
+func readTerminated(t *testing.T, input string) [][]string {
+
+	t.Helper()
+
+	term, _ := InputTerminator()
+	r := sourceReader{Reader: csv.NewReader(TerminatedReader(strings.NewReader(input))), term: term, terminated: true}
+
+	rows := [][]string{}
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			return rows
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows = append(rows, row)
+	}
+}
+
+func TestReadTerminated(t *testing.T) {
+
+	defer func(old string) { *recordTerminator = old }(*recordTerminator)
+	*recordTerminator = `\x1f`
+
+	got := readTerminated(t, "id,note\x1f1,\"quoted \x1f unit sep\"\x1f2,raw\nnewline\x1f3,\"quoted\nnewline\"\x1f")
+	want := [][]string{
+		{"id", "note"},
+		{"1", "quoted \x1f unit sep"},
+		{"2", "raw\nnewline"},
+		{"3", "quoted\nnewline"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("read %q, want %q", got, want)
+	}
+}
+
+func TestWriteTerminated(t *testing.T) {
+
+	defer func(old string) { *recordTerminator = old }(*recordTerminator)
+	*recordTerminator = "nul"
+
+	rows := [][]string{{"id", "note"}, {"1", "two\nlines"}, {"2", "comma, and \x00 nul"}}
+
+	buf := &bytes.Buffer{}
+	w := csv.NewWriter(TerminatedWriter(buf))
+	w.WriteAll(rows)
+	if err := w.Error(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := readTerminated(t, buf.String()); !reflect.DeepEqual(got, rows) {
+		t.Errorf("round trip gave %q, want %q", got, rows)
+	}
+
+	w = csv.NewWriter(TerminatedWriter(&bytes.Buffer{}))
+	w.WriteAll([][]string{{"bare\x00nul"}})
+	if w.Error() == nil {
+		t.Error("an unquoted value holding the terminator was written")
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+)
+
+var (
+	keyTimeout      = flag.Duration("key-timeout", 0, "skip any key whose joined rows take longer than this to produce (e.g. 30s)")
+	skippedKeysFile = flag.String("skipped-keys", "", "file to record keys skipped by --key-timeout")
+
+	skippedKeys   int
+	skippedOut    *OutputFile
+	skippedWriter *csv.Writer
+)
+
+// OpenSkippedKeys creates the --skipped-keys file, if one is named.
+func OpenSkippedKeys() {
+
+	skippedKeys = 0
+
+	if *skippedKeysFile == "" {
+		return
+	}
+
+	skippedOut = CreateOutput(*skippedKeysFile)
+	skippedWriter = csv.NewWriter(skippedOut)
+	skippedWriter.Write([]string{"key"})
+}
+
+// SkipKey records a key that was skipped because it ran out of time.
+func SkipKey(key string) {
+
+	skippedKeys++
+
+	if skippedWriter != nil {
+		skippedWriter.Write([]string{key})
+		skippedOut.Rows++
+	}
+}
+
+// CloseSkippedKeys finishes the --skipped-keys file and reports how many keys
+// were skipped.
+func CloseSkippedKeys() {
+
+	if skippedKeys > 0 {
+		log.Printf("skipped %d keys that took longer than %v", skippedKeys, *keyTimeout)
+	}
+
+	if skippedWriter == nil {
+		return
+	}
+
+	skippedWriter.Flush()
+	err := skippedWriter.Error()
+	if err == nil {
+		err = skippedOut.Close()
+	}
+	if err != nil {
+		fail(&RunError{Class: errIO, File: *skippedKeysFile, Message: fmt.Sprintf("failed writing skipped keys file: %v", err)})
+	}
+
+	skippedOut, skippedWriter = nil, nil
+}
+
+// keyDeadline returns when the current key must be finished by, or the zero
+// time if there is no limit.
+func keyDeadline() time.Time {
+
+	if *keyTimeout <= 0 {
+		return time.Time{}
+	}
+
+	return time.Now().Add(*keyTimeout)
+}
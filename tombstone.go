@@ -0,0 +1,88 @@
+package main
+
+import (
+	"flag"
+	"log"
+)
+
+var (
+	tombstoneOpts listFlag
+	markDeleted   = flag.Bool("mark-deleted", false, "with --tombstone, keep deleted keys' rows and add a deleted column saying which they are")
+
+	// tombstones holds each input's --tombstone expression, indexed like
+	// inputNames, nil for inputs without one.
+	tombstones []*Expr
+
+	// deletedKeys holds the keys of the tombstone records read.
+	deletedKeys map[string]bool
+)
+
+func init() {
+	flag.Var(&tombstoneOpts, "tombstone", "treat matching records as deletions of their key, as file:expr such as b:deleted_at!=''. may be repeated")
+}
+
+// ResolveTombstones compiles the --tombstone expressions against each input's
+// header.
+func ResolveTombstones(fileNames []string, allHeaders [][]string) {
+
+	tombstones = make([]*Expr, len(fileNames))
+	deletedKeys = map[string]bool{}
+
+	for _, spec := range tombstoneOpts {
+
+		file, src, ok := splitPair(spec, ":")
+		if !ok {
+			usagef("--tombstone %s must be file:expr", spec)
+		}
+
+		i := FileIndex(fileNames, file)
+		if i < 0 {
+			usagef("--tombstone names %s, which is not an input file", file)
+		}
+
+		expr, err := CompileExpr(src, allHeaders[i])
+		if err != nil {
+			usagef("--tombstone for %s: %v", fileNames[i], err)
+		}
+
+		tombstones[i] = expr
+	}
+}
+
+// IsTombstone reports whether a record from input src marks its key deleted.
+func IsTombstone(src int, rec Record) bool {
+
+	if tombstones[src] == nil {
+		return false
+	}
+
+	return tombstones[src].Match(func(col string) string {
+		return rec[col]
+	})
+}
+
+// keyDeleted reports whether a key's rows are left out because a tombstone
+// deleted it.
+func keyDeleted(key string) bool {
+	return deletedKeys[key] && !*markDeleted
+}
+
+// markingDeletes reports whether output rows carry a deleted column.
+func markingDeletes() bool {
+	return *markDeleted && len(tombstoneOpts) > 0
+}
+
+// ReportDeletedKeys logs how many keys the tombstones removed or marked.
+func ReportDeletedKeys() {
+
+	if len(deletedKeys) == 0 {
+		return
+	}
+
+	if markingDeletes() {
+		log.Printf("marked %d keys deleted by tombstone records", len(deletedKeys))
+		return
+	}
+
+	log.Printf("suppressed %d keys deleted by tombstone records", len(deletedKeys))
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+var (
+	allowedOpts  listFlag
+	onDisallowed = flag.String("on-disallowed", "warn", "what to do with rows holding values not listed by --allowed: warn, drop or fail")
+)
+
+func init() {
+	flag.Var(&allowedOpts, "allowed", "the values a column may hold, as col=v1,v2,... may be repeated")
+}
+
+// Validator checks records from one input against --allowed.
+type Validator struct {
+	file    string
+	allowed map[string]map[string]bool
+	action  string
+	bad     map[string]int
+	rows    int
+}
+
+// NewValidator returns the Validator for an input with the given headers, or
+// nil if none of its columns are restricted.
+func NewValidator(headers []string, file string) *Validator {
+
+	switch *onDisallowed {
+	case "warn", "drop", "fail":
+	default:
+		usagef("--on-disallowed %s must be warn, drop or fail", *onDisallowed)
+	}
+
+	v := &Validator{
+		file:    file,
+		allowed: map[string]map[string]bool{},
+		action:  *onDisallowed,
+		bad:     map[string]int{},
+	}
+
+	for _, spec := range allowedOpts {
+		col, values, ok := splitPair(spec, "=")
+		if !ok {
+			usagef("--allowed %s must be col=v1,v2,...", spec)
+		}
+		if !contains(headers, col) {
+			continue
+		}
+		set := map[string]bool{}
+		for _, value := range strings.Split(values, ",") {
+			set[value] = true
+		}
+		v.allowed[col] = set
+	}
+
+	if len(v.allowed) == 0 {
+		return nil
+	}
+
+	return v
+}
+
+// Check reports whether the record should be kept.
+func (v *Validator) Check(rec Record, recNum int) bool {
+
+	ok := true
+
+	for col, set := range v.allowed {
+		if set[rec[col]] {
+			continue
+		}
+		if v.action == "fail" {
+			fail(&RunError{
+				Class:   errInput,
+				File:    v.file,
+				Record:  recNum,
+				Message: fmt.Sprintf("%s record %d: %s has unexpected value %q", v.file, recNum, col, rec[col]),
+				Hint:    "add the value to --allowed if it is legitimate",
+			})
+		}
+		v.bad[fmt.Sprintf("%s=%q", col, rec[col])]++
+		ok = false
+	}
+
+	if !ok {
+		v.rows++
+	}
+
+	return ok || v.action == "warn"
+}
+
+// Report logs the unexpected values seen, most frequent first.
+func (v *Validator) Report() {
+
+	if v.rows == 0 {
+		return
+	}
+
+	values := []string{}
+	for value := range v.bad {
+		values = append(values, value)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if v.bad[values[i]] != v.bad[values[j]] {
+			return v.bad[values[i]] > v.bad[values[j]]
+		}
+		return values[i] < values[j]
+	})
+
+	const shown = 5
+	summary := []string{}
+	for i, value := range values {
+		if i == shown {
+			summary = append(summary, fmt.Sprintf("and %d more", len(values)-shown))
+			break
+		}
+		summary = append(summary, fmt.Sprintf("%s (%d)", value, v.bad[value]))
+	}
+
+	verb := "kept"
+	if v.action == "drop" {
+		verb = "dropped"
+	}
+
+	log.Printf("%s: %s %d rows with unexpected values: %s", v.file, verb, v.rows, strings.Join(summary, ", "))
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// timeLayouts are the timestamp formats recognised when a column's values
+// need to be read as times.
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"01/02/2006 15:04:05",
+	"01/02/2006",
+	"Jan 2 2006",
+	"Jan 2, 2006",
+	"2 Jan 2006",
+	time.RFC1123Z,
+	time.RFC1123,
+}
+
+// parseNumber reads a value as a number.
+func parseNumber(s string) (float64, bool) {
+
+	f, err := strconv.ParseFloat(strings.TrimSpace(numberText(s)), 64)
+
+	return f, err == nil
+}
+
+// parseTime reads a value as a timestamp, trying each of timeLayouts.
+func parseTime(s string) (time.Time, bool) {
+
+	s = strings.TrimSpace(s)
+
+	for _, layout := range timeLayouts {
+		t, err := time.Parse(layout, s)
+		if err == nil {
+			return t, true
+		}
+	}
+
+	return time.Time{}, false
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var venn = flag.Bool("venn", false, "instead of joining, output the number of keys found in each exact combination of inputs")
+
+// WriteVenn writes the --venn summary: for each combination of inputs, the
+// number of keys found in exactly those inputs and no others. With up to
+// eight inputs every combination is listed, including empty ones, so the
+// regions of the diagram are all there; beyond that only those with keys.
+// The output options are for joined rows, so the summary is written past
+// them.
+func WriteVenn(keys []string, allData []DataCollection, fileNames []string, w RowWriter) {
+
+	if len(allData) > 64 {
+		usagef("--venn works with at most 64 inputs")
+	}
+
+	if ow, ok := w.(*OutputWriter); ok {
+		sink, err := ow.Sink()
+		if err != nil {
+			fail(&RunError{Class: errOutput, Message: fmt.Sprintf("failed to write CSV output: %v", err)})
+		}
+		w = sink
+	}
+
+	counts := map[uint64]int{}
+
+	for _, key := range keys {
+		var mask uint64
+		for i, dc := range allData {
+			if len(dc.data[key]) > 0 {
+				mask |= 1 << i
+			}
+		}
+		counts[mask]++
+	}
+
+	masks := []uint64{}
+	if len(allData) <= 8 {
+		for mask := uint64(1); mask < 1<<len(allData); mask++ {
+			masks = append(masks, mask)
+		}
+	} else {
+		for mask := range counts {
+			masks = append(masks, mask)
+		}
+	}
+	sortMasks(masks)
+
+	err := w.Write([]string{"inputs", "sources", "keys", "percent"})
+
+	for _, mask := range masks {
+		if err != nil {
+			break
+		}
+		names := []string{}
+		for i := range allData {
+			if mask&(1<<i) != 0 {
+				names = append(names, fileNames[i])
+			}
+		}
+		percent := 0.0
+		if len(keys) > 0 {
+			percent = float64(counts[mask]) / float64(len(keys)) * 100
+		}
+		err = w.Write([]string{
+			strconv.Itoa(len(names)),
+			strings.Join(names, "&"),
+			strconv.Itoa(counts[mask]),
+			strconv.FormatFloat(percent, 'f', 1, 64),
+		})
+	}
+
+	if err != nil {
+		fail(&RunError{Class: errOutput, Message: fmt.Sprintf("failed to write CSV output: %v", err)})
+	}
+}
+
+// bitCount returns the number of inputs in a combination.
+func bitCount(mask uint64) int {
+
+	n := 0
+	for ; mask != 0; mask &= mask - 1 {
+		n++
+	}
+
+	return n
+}
+
+// sortMasks orders combinations by size, then by the inputs they hold in
+// command line order.
+func sortMasks(masks []uint64) {
+
+	sort.Slice(masks, func(i, j int) bool {
+		a, b := masks[i], masks[j]
+		if bitCount(a) != bitCount(b) {
+			return bitCount(a) < bitCount(b)
+		}
+		// The lowest input in one but not the other decides.
+		diff := a ^ b
+		return a&(diff&-diff) != 0
+	})
+}
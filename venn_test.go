@@ -0,0 +1,39 @@
+package main
+
+import (
+	"encoding/csv"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestVennSkipsOutputOptions(t *testing.T) {
+
+	defer func(v bool, hash string, consts listFlag) {
+		*venn, *hashColumns, constOpts = v, hash, consts
+	}(*venn, *hashColumns, constOpts)
+
+	// Neither applies to the summary, and --hash left would fail on it.
+	*venn = true
+	*hashColumns = "left"
+	constOpts = listFlag{"batch=1"}
+
+	out := &rowCollector{}
+	ow := NewOutputWriter(out)
+	readers := []RowReader{
+		csv.NewReader(strings.NewReader("id,left\n1,a\n2,b\n")),
+		csv.NewReader(strings.NewReader("id,right\n2,c\n3,d\n")),
+	}
+	Run(readers, []string{"a.csv", "b.csv"}, ow)
+	ow.Flush()
+
+	want := [][]string{
+		{"inputs", "sources", "keys", "percent"},
+		{"1", "a.csv", "1", "33.3"},
+		{"1", "b.csv", "1", "33.3"},
+		{"2", "a.csv&b.csv", "1", "33.3"},
+	}
+	if !reflect.DeepEqual(out.rows, want) {
+		t.Errorf("venn summary = %q, want %q", out.rows, want)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"os"
+	"strings"
+)
+
+var verifyOpts listFlag
+
+func init() {
+	flag.Var(&verifyOpts, "verify-input", "check an input's checksum before joining, as file=sha256:<hex> (or sha512). may be repeated")
+}
+
+// VerifyInputs checks every input named by --verify-input against its
+// expected checksum, failing before anything is joined if one differs.
+func VerifyInputs(fileNames []string) {
+
+	for i, expected := range perFileOptions("--verify-input", verifyOpts, fileNames) {
+
+		algo, want, ok := splitPair(expected, ":")
+		if !ok {
+			usagef("--verify-input for %s must be sha256:<hex> or sha512:<hex>", fileNames[i])
+		}
+
+		var h hash.Hash
+		switch algo {
+		case "sha256":
+			h = sha256.New()
+		case "sha512":
+			h = sha512.New()
+		default:
+			usagef("--verify-input for %s uses %s. use sha256 or sha512", fileNames[i], algo)
+		}
+
+		f, err := os.Open(inputPath(fileNames[i]))
+		if err == nil {
+			_, err = io.Copy(h, f)
+			f.Close()
+		}
+		if err != nil {
+			fail(&RunError{Class: errIO, File: fileNames[i], Message: fmt.Sprintf("cannot read %s to verify it: %v", fileNames[i], err)})
+		}
+
+		got := hex.EncodeToString(h.Sum(nil))
+		if !strings.EqualFold(got, want) {
+			fail(&RunError{
+				Class:   errPolicy,
+				File:    fileNames[i],
+				Message: fmt.Sprintf("%s has %s %s, not the expected %s", fileNames[i], algo, got, want),
+				Hint:    "the file is not the one that was approved",
+			})
+		}
+
+		log.Printf("verified %s %s", fileNames[i], algo)
+	}
+}
@@ -0,0 +1,45 @@
+// csvjoin.js runs csvjoin in the browser, so that CSV files can be joined
+// without uploading them anywhere.
+//
+// Build the module with
+//
+//     GOOS=js GOARCH=wasm go build -o csvjoin.wasm .
+//
+// and serve it alongside this file and wasm_exec.js from the Go distribution
+// ($(go env GOROOT)/lib/wasm/wasm_exec.js, or misc/wasm in older releases).
+//
+//     const csvjoin = await loadCsvjoin("csvjoin.wasm");
+//     const out = csvjoin.join(
+//         [{ name: "a.csv", text: aText }, { name: "b.csv", text: bText }],
+//         { keys: "id", "key-normalize": "scientific", tag: ["ssn=pii"] });
+//
+// Options are the command line options without their dashes. Repeatable
+// options take an array. join returns the joined CSV text, or throws an Error
+// carrying csvjoin's message.
+
+async function loadCsvjoin(url) {
+  const go = new Go();
+  const result = await WebAssembly.instantiateStreaming(fetch(url), go.importObject);
+  go.run(result.instance);
+
+  return {
+    join(files, options = {}) {
+      const pairs = [];
+      for (const [name, value] of Object.entries(options)) {
+        for (const v of Array.isArray(value) ? value : [value]) {
+          pairs.push([name, v]);
+        }
+      }
+
+      const result = globalThis.csvjoinText(
+        files.map((f) => f.name),
+        files.map((f) => f.text),
+        pairs);
+
+      if (result.error) {
+        throw new Error(result.error);
+      }
+      return result.output;
+    },
+  };
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// YAMLMap is a YAML mapping that remembers the order of its keys. Values are
+// strings, []string lists, or nested *YAMLMap.
+type YAMLMap struct {
+	Keys   []string
+	Values map[string]interface{}
+}
+
+// Get returns the value for key, or nil.
+func (m *YAMLMap) Get(key string) interface{} {
+	return m.Values[key]
+}
+
+// String returns the scalar value for key, or "" if it is missing or not a
+// scalar.
+func (m *YAMLMap) String(key string) string {
+	s, _ := m.Values[key].(string)
+	return s
+}
+
+// Map returns the nested mapping for key, or nil.
+func (m *YAMLMap) Map(key string) *YAMLMap {
+	n, _ := m.Values[key].(*YAMLMap)
+	return n
+}
+
+type yamlLine struct {
+	num    int
+	indent int
+	text   string
+}
+
+// ParseYAML parses the small subset of YAML used for csvjoin's own files:
+// nested mappings, lists of scalars (block or [a, b] flow style), quoted or
+// plain scalars, and # comments.
+func ParseYAML(data string) (*YAMLMap, error) {
+
+	lines := []yamlLine{}
+
+	for i, raw := range strings.Split(data, "\n") {
+		text := stripYAMLComment(strings.TrimRight(raw, " \t\r"))
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		indent := len(text) - len(strings.TrimLeft(text, " "))
+		lines = append(lines, yamlLine{num: i + 1, indent: indent, text: strings.TrimSpace(text)})
+	}
+
+	if len(lines) == 0 {
+		return &YAMLMap{Values: map[string]interface{}{}}, nil
+	}
+
+	m, rest, err := parseYAMLMap(lines, lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, fmt.Errorf("line %d: unexpected indentation", rest[0].num)
+	}
+
+	return m, nil
+}
+
+// parseYAMLMap parses mapping entries at exactly indent, returning the lines
+// that follow the mapping.
+func parseYAMLMap(lines []yamlLine, indent int) (*YAMLMap, []yamlLine, error) {
+
+	m := &YAMLMap{Values: map[string]interface{}{}}
+
+	for len(lines) > 0 && lines[0].indent == indent {
+
+		line := lines[0]
+		lines = lines[1:]
+
+		colon := strings.Index(line.text, ":")
+		if colon <= 0 || strings.HasPrefix(line.text, "- ") {
+			return nil, nil, fmt.Errorf("line %d: expected key: value", line.num)
+		}
+
+		key := yamlScalar(line.text[:colon])
+		value := strings.TrimSpace(line.text[colon+1:])
+
+		if _, dup := m.Values[key]; dup {
+			return nil, nil, fmt.Errorf("line %d: duplicate key %s", line.num, key)
+		}
+		m.Keys = append(m.Keys, key)
+
+		switch {
+		case value != "" && strings.HasPrefix(value, "["):
+			if !strings.HasSuffix(value, "]") {
+				return nil, nil, fmt.Errorf("line %d: unterminated list", line.num)
+			}
+			list := []string{}
+			for _, item := range strings.Split(value[1:len(value)-1], ",") {
+				if strings.TrimSpace(item) != "" {
+					list = append(list, yamlScalar(item))
+				}
+			}
+			m.Values[key] = list
+
+		case value != "":
+			m.Values[key] = yamlScalar(value)
+
+		case len(lines) > 0 && lines[0].indent > indent && strings.HasPrefix(lines[0].text, "-"):
+			list := []string{}
+			childIndent := lines[0].indent
+			for len(lines) > 0 && lines[0].indent == childIndent && strings.HasPrefix(lines[0].text, "-") {
+				list = append(list, yamlScalar(strings.TrimPrefix(lines[0].text, "-")))
+				lines = lines[1:]
+			}
+			m.Values[key] = list
+
+		case len(lines) > 0 && lines[0].indent > indent:
+			child, rest, err := parseYAMLMap(lines, lines[0].indent)
+			if err != nil {
+				return nil, nil, err
+			}
+			m.Values[key] = child
+			lines = rest
+
+		default:
+			m.Values[key] = ""
+		}
+	}
+
+	if len(lines) > 0 && lines[0].indent > indent {
+		return nil, nil, fmt.Errorf("line %d: unexpected indentation", lines[0].num)
+	}
+
+	return m, lines, nil
+}
+
+// yamlScalar trims a scalar and removes surrounding quotes.
+func yamlScalar(s string) string {
+
+	s = strings.TrimSpace(s)
+
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		s = s[1 : len(s)-1]
+	}
+
+	return s
+}
+
+// stripYAMLComment removes a trailing # comment that is not inside quotes.
+func stripYAMLComment(s string) string {
+
+	var quote byte
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && (i == 0 || s[i-1] == ' '):
+			quote = c
+		case c == '#' && (i == 0 || s[i-1] == ' ' || s[i-1] == '\t'):
+			return s[:i]
+		}
+	}
+
+	return s
+}